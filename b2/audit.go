@@ -0,0 +1,109 @@
+package b2
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrMissingSha1 B2 has no usable SHA1 stored for a file
+var ErrMissingSha1 = errors.New("File has no SHA1")
+
+// ErrInvalidConcurrency a concurrent operation was asked to use less than one worker
+var ErrInvalidConcurrency = errors.New("Concurrency must be at least 1")
+
+// AuditResult outcome of auditing one file version, Err is set when its SHA1 could not be retrieved or is missing
+type AuditResult struct {
+	File FileName
+	Sha1 string
+	Err  error
+}
+
+// Audit checks that every file version of this bucket has a retrievable SHA1, using concurrency workers.
+// Results are streamed on the returned channel, which must be drained and is closed once the whole bucket was audited or the context of the B2 is done.
+// Large files are accepted when they carry LargeFileSha1InfoKey. A failed listing is reported as a result with an empty File
+func (b *Bucket) Audit(concurrency int) (<-chan AuditResult, error) {
+	if concurrency < 1 {
+		return nil, ErrInvalidConcurrency
+	}
+
+	ctx := b.conn.context()
+	files := make(chan FileName)
+	results := make(chan AuditResult)
+
+	send := func(result AuditResult) bool {
+		select {
+		case results <- result:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(files)
+
+		opts := &ListOptions{MaxFileCount: listPageSize}
+		for {
+			versions, nextFileID, nextFileName, err := b.conn.ListFileVersionsWithOptions(b.ID, opts)
+			if err != nil {
+				send(AuditResult{Err: err})
+				return
+			}
+
+			for _, version := range versions {
+				// hide markers have no content to audit
+				if version.Action != ActionUpload {
+					continue
+				}
+
+				select {
+				case files <- version:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if nextFileName == "" {
+				return
+			}
+
+			opts.StartFileName = nextFileName
+			opts.StartFileID = nextFileID
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				if !send(b.auditFile(file)) {
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results, nil
+}
+
+// auditFile retrieves the stored SHA1 of one file version
+func (b *Bucket) auditFile(file FileName) AuditResult {
+	info, err := b.conn.GetFileInfo(file.ID)
+	if err != nil {
+		return AuditResult{File: file, Err: err}
+	}
+
+	sha1 := info.ContentSha1()
+	if sha1 == "" {
+		return AuditResult{File: file, Err: ErrMissingSha1}
+	}
+
+	return AuditResult{File: file, Sha1: sha1}
+}
@@ -2,6 +2,7 @@ package b2
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +12,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // APIurl base address for the B2 API
@@ -28,6 +31,58 @@ const HeaderInfoPrefix = "X-Bz-Info-"
 // ErrGeneric generic error from API
 var ErrGeneric = errors.New("Received invalid response from B2 API")
 
+// ErrExpiredAuthToken the authorization token expired, authorizing again and retrying is expected to work
+var ErrExpiredAuthToken = errors.New("Authorization token expired")
+
+// ErrBadAuthToken the authorization token is not valid, authorizing again will not help
+var ErrBadAuthToken = errors.New("Authorization token is not valid")
+
+// ErrUnauthorized the authorization token is not allowed to perform the call, authorizing again will not help
+var ErrUnauthorized = errors.New("Not authorized")
+
+// ErrMalformedAuthResponse authorizing succeeded but the response lacked the account ID, API URL, download URL or token
+var ErrMalformedAuthResponse = errors.New("Received incomplete authorization from B2 API")
+
+// ErrShortRead a download ended before receiving as many bytes as B2 announced
+var ErrShortRead = errors.New("Download ended before the announced Content-Length")
+
+// ErrNotModified a conditional download found the file unchanged, nothing was written
+var ErrNotModified = errors.New("File not modified")
+
+// ErrTooManyResults a listing would accumulate more files than B2.MaxListResults
+var ErrTooManyResults = errors.New("Listing exceeds the maximum amount of results")
+
+// ErrNotFound the requested file does not exist or is hidden
+var ErrNotFound = errors.New("File not found")
+
+// ErrAlreadyHidden the latest version of the file is already hidden
+var ErrAlreadyHidden = errors.New("File already hidden")
+
+// ErrConflict a conditional update found the bucket at another revision than expected, nothing was changed
+var ErrConflict = errors.New("Bucket revision changed")
+
+// ErrCapExceeded a storage, download or transaction cap of the account was reached, retrying will fail until it is raised or reset.
+// The *Err it matches holds which cap in its Code and Message
+var ErrCapExceeded = errors.New("Account cap exceeded")
+
+// ErrInvalidDownloadURL a URL is not a download by name URL of the /file/<bucket>/<name> form
+var ErrInvalidDownloadURL = errors.New("Invalid download URL")
+
+// ErrDuplicateBucketName a bucket with the name already exists, in this account or another one
+var ErrDuplicateBucketName = errors.New("Bucket name already in use")
+
+// ErrBucketNotFound no bucket has the requested name
+var ErrBucketNotFound = errors.New("Bucket not found")
+
+// ErrMissingDownloadURL downloads need the DownloadURL B2 gives when authorizing, which is not set
+var ErrMissingDownloadURL = errors.New("Download URL is not set, authorize with NewB2 first")
+
+// ErrResponseTooLarge a JSON response of B2 exceeds B2.MaxResponseSize
+var ErrResponseTooLarge = errors.New("Response from B2 API is too large")
+
+// ErrClosed the B2 was closed and can no longer send requests
+var ErrClosed = errors.New("B2 connection is closed")
+
 // B2 communicates to B2 API and holds information for the connection
 type B2 struct {
 	AccountID   string `json:"accountId"`
@@ -35,30 +90,568 @@ type B2 struct {
 	AuthToken   string `json:"authorizationToken"`
 	DownloadURL string `json:"downloadUrl"`
 	AppKey      string `json:"-"`
+
+	// Allowed capabilities and restrictions of the application key, as reported by B2 when authorizing
+	Allowed *Allowed `json:"allowed"`
+
+	// part sizes for large files, as advised by B2 when authorizing
+	RecommendedPartSize     int64 `json:"recommendedPartSize"`
+	AbsoluteMinimumPartSize int64 `json:"absoluteMinimumPartSize"`
+
+	// Client sends every request but authorization, http.DefaultClient when nil.
+	// Its CheckRedirect decides how downloads handle redirects, see NoRedirects and KeepAuthorizationRedirects
+	Client *http.Client `json:"-"`
+
+	// AllowUnknownBucketTypes lets bucket types other than the BucketType constants through to B2
+	AllowUnknownBucketTypes bool `json:"-"`
+
+	// LargeFileThreshold size above which helpers upload local files as large files, the recommended part size when zero
+	LargeFileThreshold int64 `json:"-"`
+
+	// DryRun records mutating calls (bucket create, update and delete, file upload, copy, hide and delete)
+	// in PlannedActions instead of sending them, while reads are still sent
+	DryRun bool `json:"-"`
+
+	// RequestHook is called with every request right before it is sent, after its own headers were set.
+	// It may add headers, but the Authorization header is always restored afterwards
+	RequestHook func(*http.Request) `json:"-"`
+
+	// Context the parent of every request of the B2 and its copies, context.Background when nil. It is read on the first request,
+	// Cancel aborts everything derived from it
+	Context context.Context `json:"-"`
+
+	// OperationTimeout bounds the total time of operations spanning multiple requests, zero means no limit
+	OperationTimeout time.Duration `json:"-"`
+
+	// MetadataTimeout bounds each API call other than uploads and downloads, including reading its response, zero means no limit
+	MetadataTimeout time.Duration `json:"-"`
+
+	// TransferTimeout bounds each upload or download request, including reading the downloaded content, zero means no limit
+	TransferTimeout time.Duration `json:"-"`
+
+	// RateLimit throttles upload and download bodies, it may be shared between several B2 to cap them together
+	RateLimit *RateLimiter `json:"-"`
+
+	// Concurrency the amount of workers used by batch operations, DefaultConcurrency when zero
+	Concurrency int `json:"-"`
+
+	// MaxListResults the most files helpers listing a whole bucket or prefix accumulate in memory, DefaultMaxListResults when zero
+	MaxListResults int `json:"-"`
+
+	// DefaultPageSize the files requested by listing calls given a count of zero, DefaultListPageSize when zero rather than B2's 100
+	DefaultPageSize int `json:"-"`
+
+	// Encryption the SSE-C key sent with every download and HEAD request, files encrypted with another key or none fail
+	Encryption *CustomerKey `json:"-"`
+
+	// UploadURLTTL how long an upload URL is reused before getting a new one, DefaultUploadURLTTL when zero
+	UploadURLTTL time.Duration `json:"-"`
+
+	// MaxRetries how many times an upload is sent again with a new upload URL after a failure that may be temporary,
+	// such as a 503 or a dropped connection. Retried data must be an io.ReadSeeker or at most RetryBufferSize bytes.
+	// An expired upload token is always retried once when the data is an io.ReadSeeker
+	MaxRetries int `json:"-"`
+
+	// Backoff decides how long retries wait, DefaultBackoff when nil
+	Backoff Backoff `json:"-"`
+
+	// RetryBufferSize the largest upload buffered in memory to be retried when its data is not an io.ReadSeeker, DefaultRetryBufferSize when zero
+	RetryBufferSize int64 `json:"-"`
+
+	// MaxConcurrentRequests the most requests in flight at once across every copy of this B2, a request lasting until its
+	// response body is closed. Zero means no limit, it must be set before the first request.
+	// Bucket.TransformFile keeps its download open while uploading, so it fails with ErrTooFewRequests with a limit of 1
+	MaxConcurrentRequests int `json:"-"`
+
+	// ExpectContinue sends uploads with "Expect: 100-continue", so B2 may refuse them, such as for an expired token, before the
+	// content is sent. The Transport of Client waits its ExpectContinueTimeout for the answer, one second for http.DefaultTransport,
+	// and sends the content at once when it is zero
+	ExpectContinue bool `json:"-"`
+
+	// CopyBufferSize the size of the pooled buffers downloads and hashing copy through, DefaultCopyBufferSize when zero.
+	// Larger buffers may improve throughput on fast links
+	CopyBufferSize int `json:"-"`
+
+	// DownloadDecompress decompresses whole file downloads stored with a gzip or deflate Content-Encoding, such as set with
+	// InfoKeyContentEncoding, before writing them. Their SHA1 is verified on the compressed bytes as stored by B2
+	DownloadDecompress bool `json:"-"`
+
+	// MaxDownloadBytes the biggest file DownloadBytesByName and DownloadBytesByID load into memory, DefaultMaxDownloadBytes when zero
+	MaxDownloadBytes int64 `json:"-"`
+
+	// MaxResponseSize the most bytes of a JSON response, such as a listing or an error, read into memory, DefaultMaxResponseSize
+	// when zero. Larger responses fail with ErrResponseTooLarge, downloads are streamed and not limited
+	MaxResponseSize int64 `json:"-"`
+
+	// CircuitBreaker refuses requests with ErrCircuitOpen during outages when set, it may be shared between several B2
+	CircuitBreaker *CircuitBreaker `json:"-"`
+
+	// Metrics receives request, retry and transfer observations when set
+	Metrics Metrics `json:"-"`
+
+	ctx    context.Context
+	root   *B2
+	shared *shared
+}
+
+// shared mutable state common to a B2 and every copy made of it with WithContext
+type shared struct {
+	mu          sync.Mutex
+	lastHeaders http.Header
+	uploads     map[string][]*Upload
+	planned     []PlannedAction
+	closed      bool
+	requests    chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+// sharedMu guards the lazy creation of shared state
+var sharedMu sync.Mutex
+
+// state returns the state shared by b and its copies
+func (b *B2) state() *shared {
+	root := b.base()
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if root.shared == nil {
+		root.shared = &shared{}
+	}
+
+	return root.shared
+}
+
+// parent returns the context every request of b and its copies derives from, created from Context on first use
+func (b *B2) parent() context.Context {
+	state := b.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.ctx == nil {
+		parent := b.base().Context
+		if parent == nil {
+			parent = context.Background()
+		}
+
+		state.ctx, state.cancel = context.WithCancel(parent)
+	}
+
+	return state.ctx
+}
+
+// Cancel aborts every request in flight of the B2 and of its copies made with WithContext, such as uploads, downloads and listings.
+// Every later request fails with context.Canceled, create a new B2 to send requests again
+func (b *B2) Cancel() {
+	b.parent()
+
+	state := b.state()
+	state.mu.Lock()
+	cancel := state.cancel
+	state.mu.Unlock()
+
+	cancel()
+}
+
+// LastResponseHeaders returns the headers of the most recent response received from B2, such as X-Bz-Request-Id
+func (b *B2) LastResponseHeaders() http.Header {
+	state := b.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	return state.lastHeaders.Clone()
+}
+
+// Close releases the pooled upload URLs and idle connections of a custom Client.
+// The B2 and every copy made of it with WithContext are unusable afterwards, their requests fail with ErrClosed.
+// Closing more than once is safe
+func (b *B2) Close() error {
+	state := b.state()
+
+	state.mu.Lock()
+	if state.closed {
+		state.mu.Unlock()
+		return nil
+	}
+
+	state.closed = true
+	state.uploads = nil
+	state.mu.Unlock()
+
+	if b.Client != nil {
+		b.Client.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+// String describes the connection with its credentials redacted
+func (b B2) String() string {
+	return fmt.Sprintf("{AccountID:%s APIUrl:%s DownloadURL:%s AuthToken:%s AppKey:%s}", b.AccountID, b.APIUrl, b.DownloadURL, redact(b.AuthToken), redact(b.AppKey))
+}
+
+// GoString describes the connection with its credentials redacted for the %#v verb
+func (b B2) GoString() string {
+	return fmt.Sprintf("b2.B2{AccountID:%q, APIUrl:%q, DownloadURL:%q, AuthToken:%q, AppKey:%q}", b.AccountID, b.APIUrl, b.DownloadURL, redact(b.AuthToken), redact(b.AppKey))
+}
+
+// redact masks a secret so it is never printed, while still showing whether it is set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return "REDACTED"
+}
+
+// transportError names the endpoint of a request that failed before B2 answered, with any download authorization
+// token of its URL redacted since net/http prints the whole URL
+func transportError(req *http.Request, err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(urlErr.URL)
+	}
+
+	return fmt.Errorf("%s: %w", requestEndpoint(req), err)
+}
+
+// redactURL masks the Authorization query parameter of a download URL, which carries a download authorization token
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := parsed.Query()
+	if q.Get("Authorization") == "" {
+		return rawURL
+	}
+
+	q.Set("Authorization", redact(q.Get("Authorization")))
+	parsed.RawQuery = q.Encode()
+
+	return parsed.String()
+}
+
+// WithContext returns a shallow copy of b whose requests are bound to ctx.
+// Buckets and files returned through the copy refer back to the original B2
+func (b *B2) WithContext(ctx context.Context) *B2 {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	b2 := *b
+	b2.ctx = ctx
+	b2.root = b.base()
+
+	return &b2
+}
+
+// base returns the B2 that copies made by WithContext were derived from
+func (b *B2) base() *B2 {
+	if b == nil {
+		return nil
+	}
+
+	if b.root != nil {
+		return b.root
+	}
+
+	return b
+}
+
+// context returns the context requests are bound to
+func (b *B2) context() context.Context {
+	if b.ctx != nil {
+		return b.ctx
+	}
+
+	return b.parent()
+}
+
+// operation derives a B2 for an operation spanning multiple requests, bounded by OperationTimeout.
+// The returned cancel func must be called once the operation is done
+func (b *B2) operation() (*B2, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if b.OperationTimeout > 0 {
+		ctx, cancel = context.WithTimeout(b.context(), b.OperationTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(b.context())
+	}
+
+	return b.WithContext(ctx), cancel
+}
+
+// client the http.Client requests are sent with
+func (b *B2) client() *http.Client {
+	if b.Client != nil {
+		return b.Client
+	}
+
+	return http.DefaultClient
+}
+
+// do sends an HTTP request bound to this connection's context
+func (b *B2) do(req *http.Request) (*http.Response, error) {
+	if b == nil {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, transportError(req, err)
+		}
+
+		return resp, nil
+	}
+
+	state := b.state()
+	state.mu.Lock()
+	closed := state.closed
+	state.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	if b.CircuitBreaker != nil {
+		err := b.CircuitBreaker.allow()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", requestEndpoint(req), err)
+		}
+	}
+
+	release, err := b.acquireRequest()
+	if err != nil {
+		b.recordOutcome(false, false)
+		return nil, fmt.Errorf("%s: %w", requestEndpoint(req), err)
+	}
+
+	ctx := b.context()
+	cancel := context.CancelFunc(func() {})
+	if timeout := b.requestTimeout(req); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	// the context given to WithContext may not derive from the parent one, Cancel has to abort the request all the same
+	if b.ctx != nil {
+		var cancelRequest context.CancelFunc
+		ctx, cancelRequest = context.WithCancel(ctx)
+		stop := context.AfterFunc(b.parent(), cancelRequest)
+		cancelTimeout := cancel
+		cancel = func() {
+			stop()
+			cancelRequest()
+			cancelTimeout()
+		}
+	}
+
+	req = req.WithContext(ctx)
+	if b.RequestHook != nil {
+		auth := req.Header.Get("Authorization")
+		b.RequestHook(req)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+	}
+
+	started := time.Now()
+	resp, err := b.client().Do(req)
+	if err != nil {
+		cancel()
+		release()
+		b.observeRequest(req, 0, time.Since(started))
+
+		// a cancelled operation says nothing about the health of B2, unlike a request timing out
+		b.recordOutcome(b.context().Err() == nil && b.parent().Err() == nil, false)
+		return nil, transportError(req, err)
+	}
+
+	b.recordOutcome(resp.StatusCode >= 500, true)
+
+	// the timeout and the request slot also cover reading the body, so they are only released once the body is closed
+	resp.Body = &closeNotifier{ReadCloser: resp.Body, done: func() {
+		cancel()
+		release()
+	}}
+
+	b.observeRequest(req, resp.StatusCode, time.Since(started))
+	if req.ContentLength > 0 {
+		b.addBytes(MetricsDirectionUp, req.ContentLength)
+	}
+
+	state.mu.Lock()
+	state.lastHeaders = resp.Header
+	state.mu.Unlock()
+
+	return resp, nil
+}
+
+// transferEndpoints the endpoints bounded by TransferTimeout rather than MetadataTimeout
+var transferEndpoints = map[string]bool{
+	"b2_upload_file":         true,
+	"b2_upload_part":         true,
+	"b2_download_file_by_id": true,
+	"download_file_by_name":  true,
+}
+
+// requestTimeout the timeout of a single request, TransferTimeout for uploads and downloads, MetadataTimeout otherwise
+func (b *B2) requestTimeout(req *http.Request) time.Duration {
+	if req.Method != http.MethodHead && transferEndpoints[requestEndpoint(req)] {
+		return b.TransferTimeout
+	}
+
+	return b.MetadataTimeout
+}
+
+// closeNotifier response body calling done once it is closed
+type closeNotifier struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (c *closeNotifier) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(c.done)
+	return err
+}
+
+// recordOutcome reports the outcome of a request to the CircuitBreaker, if any: failed when B2 failed,
+// else succeeded when B2 answered properly, neither when the request was given up before
+func (b *B2) recordOutcome(failed bool, succeeded bool) {
+	if b.CircuitBreaker == nil {
+		return
+	}
+
+	switch {
+	case failed:
+		b.CircuitBreaker.failure()
+	case succeeded:
+		b.CircuitBreaker.success()
+	default:
+		b.CircuitBreaker.abandon()
+	}
+}
+
+// acquireRequest waits for a free request slot when MaxConcurrentRequests is set, the returned func frees it
+func (b *B2) acquireRequest() (func(), error) {
+	if b.MaxConcurrentRequests <= 0 {
+		return func() {}, nil
+	}
+
+	state := b.state()
+	state.mu.Lock()
+	if state.requests == nil {
+		state.requests = make(chan struct{}, b.MaxConcurrentRequests)
+	}
+	requests := state.requests
+	state.mu.Unlock()
+
+	ctx := b.context()
+	select {
+	case requests <- struct{}{}:
+		return func() { <-requests }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 // Err B2 error information
 type Err struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Status  int    `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Status    int    `json:"status"`
+	RequestID string `json:"-"`
+
+	// Endpoint the API call that failed, such as b2_list_buckets
+	Endpoint string `json:"-"`
 }
 
 func (b *Err) Error() string {
-	return fmt.Sprintf("code: '%s' status: '%d' message: '%s'", b.Code, b.Status, b.Message)
+	msg := fmt.Sprintf("code: '%s' status: '%d' message: '%s'", b.Code, b.Status, b.Message)
+	if b.RequestID != "" {
+		msg += fmt.Sprintf(" request id: '%s'", b.RequestID)
+	}
+
+	if b.Endpoint != "" {
+		msg = b.Endpoint + ": " + msg
+	}
+
+	return msg
+}
+
+// errCodes the sentinel errors matching B2 error codes
+var errCodes = map[string]error{
+	"expired_auth_token": ErrExpiredAuthToken,
+	"bad_auth_token":     ErrBadAuthToken,
+	"unauthorized":       ErrUnauthorized,
+	"already_hidden":     ErrAlreadyHidden,
+	"no_such_file":       ErrNotFound,
+	"file_not_present":   ErrNotFound,
+	"not_found":          ErrNotFound,
+	"conflict":           ErrConflict,
+
+	"duplicate_bucket_name": ErrDuplicateBucketName,
+
+	"cap_exceeded":             ErrCapExceeded,
+	"storage_cap_exceeded":     ErrCapExceeded,
+	"download_cap_exceeded":    ErrCapExceeded,
+	"transaction_cap_exceeded": ErrCapExceeded,
+}
+
+// Is lets errors.Is match a B2 error against the sentinel error of its code, such as ErrExpiredAuthToken
+func (b *Err) Is(target error) bool {
+	// answers without a JSON body, such as to HEAD requests, only carry their status
+	if b.Code == "" && b.Status == http.StatusUnauthorized {
+		return target == ErrUnauthorized
+	}
+
+	// a missing file may be answered with any code, or none, by downloads, HEAD requests and b2_get_file_info alike
+	if b.Status == http.StatusNotFound && target == ErrNotFound {
+		return true
+	}
+
+	sentinel, ok := errCodes[b.Code]
+	return ok && sentinel == target
 }
 
+// DefaultMaxResponseSize the most bytes of a JSON response read into memory when B2.MaxResponseSize is not set
+const DefaultMaxResponseSize = 64 * 1024 * 1024
+
+// maxResponseSize the most bytes of a JSON response read into memory
+func (b *B2) maxResponseSize() int64 {
+	if b != nil && b.MaxResponseSize > 0 {
+		return b.MaxResponseSize
+	}
+
+	return DefaultMaxResponseSize
+}
+
+// errSnippetLength the most bytes of a non JSON error body kept in the error message
+const errSnippetLength = 256
+
 // readResp take an http response from the B2 API and unmarshal it to the appropriate type
-func readResp(resp *http.Response, output interface{}) error {
-	data, err := ioutil.ReadAll(resp.Body)
+func (b *B2) readResp(resp *http.Response, output interface{}) error {
+	defer resp.Body.Close()
+
+	endpoint := "b2"
+	if resp.Request != nil {
+		endpoint = requestEndpoint(resp.Request)
+	}
+
+	maxSize := b.maxResponseSize()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: reading response: %w", endpoint, err)
+	}
+
+	if int64(len(data)) > maxSize {
+		return fmt.Errorf("%s: %w", endpoint, ErrResponseTooLarge)
 	}
 
 	if resp.StatusCode == GoodStatus {
 		err = json.Unmarshal(data, output)
 		if err != nil {
-			return err
+			return fmt.Errorf("%s: decoding response: %w", endpoint, err)
 		}
 
 		return nil
@@ -68,15 +661,27 @@ func readResp(resp *http.Response, output interface{}) error {
 	errb2 := &Err{}
 	err = json.Unmarshal(data, errb2)
 	if err != nil {
-		return err
+		// proxies and gateways may answer with HTML or nothing at all, keep a snippet of it instead
+		errb2 = &Err{Message: strings.TrimSpace(string(data))}
+		if len(errb2.Message) > errSnippetLength {
+			errb2.Message = errb2.Message[:errSnippetLength] + "..."
+		}
+
+		if errb2.Message == "" {
+			errb2.Message = http.StatusText(resp.StatusCode)
+		}
 	}
 
+	errb2.Status = resp.StatusCode
+	errb2.RequestID = resp.Header.Get("X-Bz-Request-Id")
+	errb2.Endpoint = endpoint
+
 	return errb2
 }
 
 func (b *B2) readHeaderFileInfo(header http.Header) (*FileInfo, error) {
 	var err error
-	info := &FileInfo{conn: b}
+	info := &FileInfo{conn: b.base(), Info: map[string]string{}}
 	info.AccountID = b.AccountID
 	info.Type = header.Get("Content-Type")
 	info.ID = header.Get("X-Bz-File-Id")
@@ -90,13 +695,19 @@ func (b *B2) readHeaderFileInfo(header http.Header) (*FileInfo, error) {
 	}
 	info.Sha1 = header.Get("X-Bz-Content-Sha1")
 
+	if mode := header.Get("X-Bz-Server-Side-Encryption"); mode != "" {
+		info.ServerSideEncryption = &ServerSideEncryption{Mode: mode}
+	} else if algorithm := header.Get("X-Bz-Server-Side-Encryption-Customer-Algorithm"); algorithm != "" {
+		info.ServerSideEncryption = &ServerSideEncryption{Mode: "SSE-C", Algorithm: algorithm}
+	}
+
 	for headerName, val := range header {
 		if !strings.HasPrefix(headerName, HeaderInfoPrefix) {
 			continue
 		}
 
-		// B2 does not support multiple values per header
-		info.Info[headerName[len(HeaderInfoPrefix):]] = val[0]
+		// B2 does not support multiple values per header, and net/http canonicalized the case of the name
+		info.Info[strings.ToLower(headerName[len(HeaderInfoPrefix):])] = val[0]
 	}
 
 	return info, nil
@@ -104,48 +715,120 @@ func (b *B2) readHeaderFileInfo(header http.Header) (*FileInfo, error) {
 
 // NewB2 create a new B2 API handler
 func NewB2(accountID string, applicationKey string) (*B2, error) {
+	return newB2(http.DefaultClient, accountID, applicationKey)
+}
+
+// TunedMaxIdleConns the idle connections kept by the client of NewB2Tuned across every host
+const TunedMaxIdleConns = 100
+
+// TunedMaxIdleConnsPerHost the idle connections kept by the client of NewB2Tuned per host. The API, download and upload
+// hosts of B2 are few, so the default of 2 would close and reopen connections to them under any concurrency
+const TunedMaxIdleConnsPerHost = 32
+
+// TunedIdleConnTimeout how long the client of NewB2Tuned keeps an idle connection, upload URLs are reused for longer
+// than that anyway
+const TunedIdleConnTimeout = 90 * time.Second
+
+// NewB2Tuned create a new B2 API handler like NewB2, with a Client of its own whose transport keeps
+// TunedMaxIdleConnsPerHost idle connections per host, instead of churning through ephemeral ports when requests are concurrent
+func NewB2Tuned(accountID string, applicationKey string) (*B2, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = TunedMaxIdleConns
+	transport.MaxIdleConnsPerHost = TunedMaxIdleConnsPerHost
+	transport.IdleConnTimeout = TunedIdleConnTimeout
+
+	return newB2(&http.Client{Transport: transport}, accountID, applicationKey)
+}
+
+// newB2 authorizes the account with client, which the returned B2 keeps using unless it is http.DefaultClient
+func newB2(client *http.Client, accountID string, applicationKey string) (*B2, error) {
 	req, err := http.NewRequest("GET", APIurl+APIsuffix+"/b2_authorize_account", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.SetBasicAuth(accountID, applicationKey)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("b2_authorize_account: %w", err)
 	}
 
 	b2 := &B2{}
+	if client != http.DefaultClient {
+		b2.Client = client
+	}
 
-	err = readResp(resp, b2)
+	// captive portals may answer with an empty or partial 200, which would leave every later URL broken
+	err = b2.readResp(resp, b2)
 	if err != nil {
+		if resp.StatusCode == GoodStatus {
+			return nil, ErrMalformedAuthResponse
+		}
+
 		return nil, err
 	}
 
+	if b2.AccountID == "" || b2.APIUrl == "" || b2.AuthToken == "" || b2.DownloadURL == "" {
+		return nil, ErrMalformedAuthResponse
+	}
+
 	return b2, nil
 }
 
 // CreateBucket creates a new bucket
 func (b *B2) CreateBucket(bucketName string, bucketType string) (*Bucket, error) {
-	req, err := http.NewRequest("GET", b.APIUrl+APIsuffix+"/b2_create_bucket", nil)
+	return b.CreateBucketWithOptions(bucketName, bucketType, nil)
+}
+
+// CreateBucketWithOptions creates a new bucket with the settings of opts
+func (b *B2) CreateBucketWithOptions(bucketName string, bucketType string, opts *BucketOptions) (*Bucket, error) {
+	if opts == nil {
+		opts = &BucketOptions{}
+	}
+
+	err := b.validateBucketType(bucketType)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.DryRun {
+		b.plan("b2_create_bucket", map[string]string{"bucketName": bucketName, "bucketType": bucketType})
+		return &Bucket{AccountID: b.AccountID, Name: bucketName, Type: bucketType, conn: b.base()}, nil
+	}
+
+	data, err := json.Marshal(struct {
+		AccountID                string                    `json:"accountId"`
+		BucketName               string                    `json:"bucketName"`
+		BucketType               string                    `json:"bucketType"`
+		BucketInfo               map[string]string         `json:"bucketInfo,omitempty"`
+		ReplicationConfiguration *ReplicationConfiguration `json:"replicationConfiguration,omitempty"`
+		LifecycleRules           *[]LifecycleRule          `json:"lifecycleRules,omitempty"`
+	}{
+		AccountID:                b.AccountID,
+		BucketName:               bucketName,
+		BucketType:               bucketType,
+		BucketInfo:               opts.Info,
+		ReplicationConfiguration: opts.ReplicationConfiguration,
+		LifecycleRules:           opts.lifecycleRules(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", b.APIUrl+APIsuffix+"/b2_create_bucket", bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("Authorization", b.AuthToken)
-	q := req.URL.Query()
-	q.Add("accountId", b.AccountID)
-	q.Add("bucketName", bucketName)
-	q.Add("bucketType", bucketType)
-	req.URL.RawQuery = q.Encode()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	bucket := &Bucket{conn: b}
-	err = readResp(resp, bucket)
+	bucket := &Bucket{conn: b.base()}
+	err = b.readResp(resp, bucket)
 	if err != nil {
 		return nil, err
 	}
@@ -155,6 +838,11 @@ func (b *B2) CreateBucket(bucketName string, bucketType string) (*Bucket, error)
 
 // DeleteBucket deletes the bucket specified
 func (b *B2) DeleteBucket(bucketID string) (*Bucket, error) {
+	if b.DryRun {
+		b.plan("b2_delete_bucket", map[string]string{"bucketId": bucketID})
+		return &Bucket{AccountID: b.AccountID, ID: bucketID, conn: b.base()}, nil
+	}
+
 	data, err := json.Marshal(map[string]string{
 		"accountId": b.AccountID,
 		"bucketId":  bucketID,
@@ -170,14 +858,14 @@ func (b *B2) DeleteBucket(bucketID string) (*Bucket, error) {
 
 	req.Header.Add("Authorization", b.AuthToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	bucket := &Bucket{conn: b}
+	bucket := &Bucket{conn: b.base()}
 
-	err = readResp(resp, bucket)
+	err = b.readResp(resp, bucket)
 	if err != nil {
 		return nil, err
 	}
@@ -201,13 +889,13 @@ func (b *B2) GetUploadURL(bucketID string) (*Upload, error) {
 
 	req.Header.Add("Authorization", b.AuthToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	upload := &Upload{}
-	err = readResp(resp, upload)
+	upload := &Upload{conn: b.base(), obtained: time.Now()}
+	err = b.readResp(resp, upload)
 	if err != nil {
 		return nil, err
 	}
@@ -215,77 +903,343 @@ func (b *B2) GetUploadURL(bucketID string) (*Upload, error) {
 	return upload, nil
 }
 
+// download sends a download request and copies the file it returns to output
+func (b *B2) download(req *http.Request, output io.Writer) (*FileInfo, error) {
+	// asking for an encoding keeps the transport from decompressing gzip on its own, so the bytes match B2's SHA1
+	if req.Header.Get("Accept-Encoding") == "" {
+		if b.DownloadDecompress {
+			req.Header.Set("Accept-Encoding", "gzip, deflate")
+		} else {
+			req.Header.Set("Accept-Encoding", "identity")
+		}
+	}
+
+	err := b.encrypt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, ErrNotModified
+	}
+
+	// only happens when the client's CheckRedirect did not follow it
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		resp.Body.Close()
+		return nil, &RedirectError{Status: resp.StatusCode, Location: resp.Header.Get("Location")}
+	}
+
+	if resp.StatusCode != GoodStatus && resp.StatusCode != http.StatusPartialContent {
+		return nil, b.readResp(resp, nil)
+	}
+
+	defer resp.Body.Close()
+
+	if checker, ok := output.(lengthChecker); ok {
+		err = checker.checkLength(resp.ContentLength)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	decoder, err := b.decompressor(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = resp.Body
+	if decoder != nil {
+		defer decoder.Close()
+		body = decoder
+	}
+
+	n, err := b.copyBuffered(b.limitWriter(output), body)
+
+	// the raw length is what the announced Content-Length and the SHA1 are about
+	raw := n
+	if decoder != nil && err == nil {
+		err = decoder.verify(resp.Header.Get("X-Bz-Content-Sha1"))
+		raw = decoder.raw.size
+	}
+
+	b.addBytes(MetricsDirectionDown, raw)
+
+	// net/http reports a body ending before its Content-Length as an unexpected EOF
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrShortRead
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a dropped connection may end the body early without an error
+	if resp.ContentLength >= 0 && raw != resp.ContentLength {
+		return nil, ErrShortRead
+	}
+
+	info, err := b.readHeaderFileInfo(resp.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	if decoder != nil {
+		info.Decompressed = true
+		info.DecompressedLength = n
+	}
+
+	// the request of the response is the last one made when redirects were followed
+	info.URL = redactURL(resp.Request.URL.String())
+
+	// ranged responses announce the length of the range, the whole file's length is in Content-Range
+	if resp.StatusCode == http.StatusPartialContent {
+		contentRange := resp.Header.Get("Content-Range")
+		total, err := strconv.ParseInt(contentRange[strings.LastIndex(contentRange, "/")+1:], 10, 64)
+		if err == nil {
+			info.Length = total
+		}
+	}
+
+	return info, nil
+}
+
 // DownloadFileByID Downloads one file from B2
 func (b *B2) DownloadFileByID(fileID string, output io.Writer) (*FileInfo, error) {
-	req, err := http.NewRequest("GET", b.DownloadURL+APIsuffix+"/b2_download_file_by_id", nil)
+	return b.DownloadFileByIDWithParams(fileID, nil, output)
+}
+
+// DownloadFileByIDWithParams downloads one file from B2 like DownloadFileByID, with params added to the query of the request,
+// such as b2ContentDisposition to override a response header
+func (b *B2) DownloadFileByIDWithParams(fileID string, params url.Values, output io.Writer) (*FileInfo, error) {
+	downloadURL, err := b.downloadURL()
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Authorization", b.AuthToken)
+	req, err := http.NewRequest("GET", downloadURL+APIsuffix+"/b2_download_file_by_id", nil)
+	if err != nil {
+		return nil, err
+	}
 
 	q := req.URL.Query()
 	q.Add("fileId", fileID)
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+	b.authorizeDownload(req, params)
+
+	return b.download(req, output)
+}
+
+// authorizeDownload adds params to the query of a download request, and the Authorization header unless params
+// already carry an authorization such as a download authorization token
+func (b *B2) authorizeDownload(req *http.Request, params url.Values) {
+	if len(params) > 0 {
+		q := req.URL.Query()
+		for name, values := range params {
+			for _, value := range values {
+				q.Add(name, value)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
 	}
 
-	if resp.StatusCode != GoodStatus {
-		return nil, readResp(resp, nil)
+	if params.Get("Authorization") == "" {
+		req.Header.Add("Authorization", b.AuthToken)
 	}
+}
 
-	defer resp.Body.Close()
+// DownloadFileRangeByID downloads the bytes from start to end, inclusive, of one file from B2.
+// The returned FileInfo holds the length of the whole file
+func (b *B2) DownloadFileRangeByID(fileID string, start int64, end int64, output io.Writer) (*FileInfo, error) {
+	downloadURL, err := b.downloadURL()
+	if err != nil {
+		return nil, err
+	}
 
-	_, err = io.Copy(output, resp.Body)
+	req, err := http.NewRequest("GET", downloadURL+APIsuffix+"/b2_download_file_by_id", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return b.readHeaderFileInfo(resp.Header)
+	req.Header.Add("Authorization", b.AuthToken)
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	q := req.URL.Query()
+	q.Add("fileId", fileID)
+	req.URL.RawQuery = q.Encode()
+
+	return b.download(req, output)
 }
 
-// DownloadFileByName downloads one file by providing the name of the bucket and the name of the file
-func (b *B2) DownloadFileByName(bucketName string, fileName string, output io.Writer) (*FileInfo, error) {
+// downloadByNameURL builds the URL used to download a file by its bucket name and file name
+func (b *B2) downloadByNameURL(bucketName string, fileName string) (string, error) {
+	err := b.checkNamePrefix(fileName)
+	if err != nil {
+		return "", err
+	}
+
 	urlFileName, err := url.Parse(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	downloadURL, err := b.downloadURL()
+	if err != nil {
+		return "", err
+	}
+
+	return downloadURL + "/file/" + bucketName + "/" + urlFileName.String(), nil
+}
+
+// downloadURL the base URL of downloads, ErrMissingDownloadURL when the B2 was not authorized with NewB2
+func (b *B2) downloadURL() (string, error) {
+	if b.DownloadURL == "" {
+		return "", ErrMissingDownloadURL
+	}
+
+	return b.DownloadURL, nil
+}
+
+// ParseDownloadURL recovers the bucket name and file name from a download by name URL of the /file/<bucket>/<name> form,
+// with the file name decoded
+func ParseDownloadURL(downloadURL string) (string, string, error) {
+	parsed, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !strings.HasPrefix(parsed.Path, "/file/") {
+		return "", "", ErrInvalidDownloadURL
+	}
+
+	bucketAndName := strings.SplitN(strings.TrimPrefix(parsed.Path, "/file/"), "/", 2)
+	if len(bucketAndName) != 2 || bucketAndName[0] == "" || bucketAndName[1] == "" {
+		return "", "", ErrInvalidDownloadURL
+	}
+
+	return bucketAndName[0], bucketAndName[1], nil
+}
+
+// GetFileInfoByName gets information about the latest version of a file by its bucket name and file name, without downloading it
+func (b *B2) GetFileInfoByName(bucketName string, fileName string) (*FileInfo, error) {
+	fileURL, err := b.downloadByNameURL(bucketName, fileName)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("GET", b.DownloadURL+"/file/"+bucketName+"/"+urlFileName.String(), nil)
+	req, err := http.NewRequest("HEAD", fileURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("Authorization", b.AuthToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	err = b.encrypt(req)
 	if err != nil {
 		return nil, err
 	}
 
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	// HEAD responses carry no body, so the error can only be built from the status, a 404 matches ErrNotFound
 	if resp.StatusCode != GoodStatus {
-		return nil, readResp(resp, nil)
+		return nil, &Err{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode), RequestID: resp.Header.Get("X-Bz-Request-Id"), Endpoint: requestEndpoint(req)}
 	}
 
-	defer resp.Body.Close()
+	return b.readHeaderFileInfo(resp.Header)
+}
+
+// DownloadFileByName downloads one file by providing the name of the bucket and the name of the file.
+// Files of private buckets are downloaded with the account's token, a token not allowed to read them fails with ErrUnauthorized
+func (b *B2) DownloadFileByName(bucketName string, fileName string, output io.Writer) (*FileInfo, error) {
+	return b.DownloadFileByNameWithParams(bucketName, fileName, nil, output)
+}
 
-	_, err = io.Copy(output, resp.Body)
+// DownloadFileByNameWithParams downloads one file by name like DownloadFileByName, with params added to the query of the request.
+// An Authorization param, such as a download authorization for a private bucket, is sent instead of the account's token
+func (b *B2) DownloadFileByNameWithParams(bucketName string, fileName string, params url.Values, output io.Writer) (*FileInfo, error) {
+	fileURL, err := b.downloadByNameURL(bucketName, fileName)
 	if err != nil {
 		return nil, err
 	}
 
-	return b.readHeaderFileInfo(resp.Header)
+	req, err := http.NewRequest("GET", fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b.authorizeDownload(req, params)
+
+	return b.download(req, output)
+}
+
+// DownloadFileByNameIfModifiedSince downloads one file by name like DownloadFileByName, unless it was not modified since t.
+// ErrNotModified is returned without writing anything to output when the file is unchanged
+func (b *B2) DownloadFileByNameIfModifiedSince(bucketName string, fileName string, t time.Time, output io.Writer) (*FileInfo, error) {
+	fileURL, err := b.downloadByNameURL(bucketName, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", b.AuthToken)
+	req.Header.Add("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+
+	return b.download(req, output)
 }
 
 // UpdateBucket update an existing bucket
 func (b *B2) UpdateBucket(bucketID string, bucketType string) (*Bucket, error) {
-	data, err := json.Marshal(map[string]string{
-		"accountId":  b.AccountID,
-		"bucketId":   bucketID,
-		"bucketType": bucketType,
+	return b.UpdateBucketWithOptions(bucketID, bucketType, nil)
+}
+
+// UpdateBucketWithOptions update an existing bucket, also replacing the settings given in opts
+func (b *B2) UpdateBucketWithOptions(bucketID string, bucketType string, opts *BucketOptions) (*Bucket, error) {
+	if opts == nil {
+		opts = &BucketOptions{}
+	}
+
+	err := b.validateBucketType(bucketType)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.DryRun {
+		b.plan("b2_update_bucket", map[string]string{"bucketId": bucketID, "bucketType": bucketType})
+		return &Bucket{AccountID: b.AccountID, ID: bucketID, Type: bucketType, conn: b.base()}, nil
+	}
+
+	data, err := json.Marshal(struct {
+		AccountID                string                    `json:"accountId"`
+		BucketID                 string                    `json:"bucketId"`
+		BucketType               string                    `json:"bucketType"`
+		BucketInfo               map[string]string         `json:"bucketInfo,omitempty"`
+		ReplicationConfiguration *ReplicationConfiguration `json:"replicationConfiguration,omitempty"`
+		LifecycleRules           *[]LifecycleRule          `json:"lifecycleRules,omitempty"`
+		IfRevisionIs             int                       `json:"ifRevisionIs,omitempty"`
+	}{
+		AccountID:                b.AccountID,
+		BucketID:                 bucketID,
+		BucketType:               bucketType,
+		BucketInfo:               opts.Info,
+		ReplicationConfiguration: opts.ReplicationConfiguration,
+		LifecycleRules:           opts.lifecycleRules(),
+		IfRevisionIs:             opts.IfRevisionIs,
 	})
 	if err != nil {
 		return nil, err
@@ -298,13 +1252,13 @@ func (b *B2) UpdateBucket(bucketID string, bucketType string) (*Bucket, error) {
 
 	req.Header.Add("Authorization", b.AuthToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	bucket := &Bucket{conn: b}
-	err = readResp(resp, bucket)
+	bucket := &Bucket{conn: b.base()}
+	err = b.readResp(resp, bucket)
 	if err != nil {
 		return nil, err
 	}
@@ -314,9 +1268,25 @@ func (b *B2) UpdateBucket(bucketID string, bucketType string) (*Bucket, error) {
 
 // DeleteFileVersion deletes one version of a file from B2
 func (b *B2) DeleteFileVersion(fileName string, fileID string) (*FileInfo, error) {
-	data, err := json.Marshal(map[string]string{
-		"fileName": fileName,
-		"fileId":   fileID,
+	return b.DeleteFileVersionBypass(fileName, fileID, false)
+}
+
+// DeleteFileVersionBypass deletes one version of a file from B2 like DeleteFileVersion. With bypassGovernance, a version
+// under a governance mode retention is deleted as well, which requires the bypassGovernance capability
+func (b *B2) DeleteFileVersionBypass(fileName string, fileID string, bypassGovernance bool) (*FileInfo, error) {
+	if b.DryRun {
+		b.plan("b2_delete_file_version", map[string]string{"fileName": fileName, "fileId": fileID, "bypassGovernance": strconv.FormatBool(bypassGovernance)})
+		return &FileInfo{AccountID: b.AccountID, ID: fileID, Name: fileName, conn: b.base()}, nil
+	}
+
+	data, err := json.Marshal(struct {
+		FileName         string `json:"fileName"`
+		FileID           string `json:"fileId"`
+		BypassGovernance bool   `json:"bypassGovernance,omitempty"`
+	}{
+		FileName:         fileName,
+		FileID:           fileID,
+		BypassGovernance: bypassGovernance,
 	})
 	if err != nil {
 		return nil, err
@@ -329,13 +1299,13 @@ func (b *B2) DeleteFileVersion(fileName string, fileID string) (*FileInfo, error
 
 	req.Header.Add("Authorization", b.AuthToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	fileInfo := &FileInfo{conn: b}
-	err = readResp(resp, fileInfo)
+	fileInfo := &FileInfo{conn: b.base()}
+	err = b.readResp(resp, fileInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -343,10 +1313,40 @@ func (b *B2) DeleteFileVersion(fileName string, fileID string) (*FileInfo, error
 	return fileInfo, nil
 }
 
-// ListBuckets lists buckets associated with an account, in alphabetical order by bucket ID
+// ListBucketsOptions optional filters of a bucket listing
+type ListBucketsOptions struct {
+	// BucketName only lists the bucket of this name
+	BucketName string
+
+	// BucketTypes only lists the buckets of these types, such as BucketTypeSnapshot, every type when empty
+	BucketTypes []string
+}
+
+// ListBuckets lists buckets associated with an account of every type, snapshot buckets included, in alphabetical order by bucket ID
 func (b *B2) ListBuckets() ([]Bucket, error) {
-	data, err := json.Marshal(map[string]string{
-		"accountId": b.AccountID,
+	return b.ListBucketsWithOptions(nil)
+}
+
+// ListBucketsWithOptions lists buckets associated with an account like ListBuckets, narrowed by opts
+func (b *B2) ListBucketsWithOptions(opts *ListBucketsOptions) ([]Bucket, error) {
+	if opts == nil {
+		opts = &ListBucketsOptions{}
+	}
+
+	bucketTypes := opts.BucketTypes
+	if len(bucketTypes) == 0 {
+		// B2 leaves snapshot buckets out unless asked for every type
+		bucketTypes = []string{"all"}
+	}
+
+	data, err := json.Marshal(struct {
+		AccountID   string   `json:"accountId"`
+		BucketName  string   `json:"bucketName,omitempty"`
+		BucketTypes []string `json:"bucketTypes,omitempty"`
+	}{
+		AccountID:   b.AccountID,
+		BucketName:  opts.BucketName,
+		BucketTypes: bucketTypes,
 	})
 	if err != nil {
 		return nil, err
@@ -359,7 +1359,7 @@ func (b *B2) ListBuckets() ([]Bucket, error) {
 
 	req.Header.Add("Authorization", b.AuthToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -367,28 +1367,153 @@ func (b *B2) ListBuckets() ([]Bucket, error) {
 	buckets := &struct {
 		Buckets []Bucket `json:"buckets"`
 	}{}
-	err = readResp(resp, buckets)
+	err = b.readResp(resp, buckets)
 	if err != nil {
 		return nil, err
 	}
 
 	for i := range buckets.Buckets {
-		buckets.Buckets[i].conn = b
+		buckets.Buckets[i].conn = b.base()
 	}
 
 	return buckets.Buckets, nil
 }
 
+// pingBucketName the bucket name Ping lists, which only needs to be a valid name
+const pingBucketName = "go-blaze-ping"
+
+// Ping checks the credentials and the connection to B2 with a cheap listing that changes nothing.
+// A key restricted to a bucket lists that bucket, as B2 requires
+func (b *B2) Ping() error {
+	bucketName := pingBucketName
+	if b.Allowed != nil && b.Allowed.BucketName != "" {
+		bucketName = b.Allowed.BucketName
+	}
+
+	_, err := b.ListBucketsWithOptions(&ListBucketsOptions{BucketName: bucketName})
+	return err
+}
+
+// GetBucketByName gets the bucket named bucketName whatever its type, ErrBucketNotFound is returned if there is none
+func (b *B2) GetBucketByName(bucketName string) (*Bucket, error) {
+	buckets, err := b.ListBucketsWithOptions(&ListBucketsOptions{BucketName: bucketName})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range buckets {
+		if buckets[i].Name == bucketName {
+			return &buckets[i], nil
+		}
+	}
+
+	return nil, ErrBucketNotFound
+}
+
+// EnsureBucket gets the bucket named bucketName, creating it with bucketType if there is none. An existing bucket keeps its type.
+// A bucket created by another process in the meantime is fetched again, ErrDuplicateBucketName is returned if
+// the name is taken by another account
+func (b *B2) EnsureBucket(bucketName string, bucketType string) (*Bucket, error) {
+	bucket, err := b.GetBucketByName(bucketName)
+	if !errors.Is(err, ErrBucketNotFound) {
+		return bucket, err
+	}
+
+	bucket, err = b.CreateBucket(bucketName, bucketType)
+	if !errors.Is(err, ErrDuplicateBucketName) {
+		return bucket, err
+	}
+
+	bucket, fetchErr := b.GetBucketByName(bucketName)
+	if fetchErr != nil {
+		return nil, err
+	}
+
+	return bucket, nil
+}
+
+// MaxFileCount the most files B2 returns from one listing call
+const MaxFileCount = 10000
+
+// ErrInvalidMaxFileCount a negative amount of files was requested from a listing
+var ErrInvalidMaxFileCount = errors.New("Invalid maximum file count")
+
+// DefaultListPageSize the files requested by a listing call given no count when B2.DefaultPageSize is not set,
+// a class C transaction is billed per 1000 files returned anyway
+const DefaultListPageSize = listPageSize
+
+// clampMaxFileCount limits a listing's file count to what B2 accepts, zero uses DefaultPageSize
+func (b *B2) clampMaxFileCount(maxFileCount int) (int, error) {
+	if maxFileCount < 0 {
+		return 0, ErrInvalidMaxFileCount
+	}
+
+	if maxFileCount == 0 {
+		maxFileCount = b.DefaultPageSize
+		if maxFileCount <= 0 {
+			maxFileCount = DefaultListPageSize
+		}
+	}
+
+	if maxFileCount > MaxFileCount {
+		return MaxFileCount, nil
+	}
+
+	return maxFileCount, nil
+}
+
+// ListOptions optional parameters narrowing a file listing
+type ListOptions struct {
+	Prefix    string
+	Delimiter string
+
+	// StartFileName resumes a listing at this name, such as the next file name of a previous page.
+	// With a Prefix it must start with the Prefix, a name outside of it lists nothing
+	StartFileName string
+	StartFileID   string
+	MaxFileCount  int
+}
+
+// outsidePrefix tells whether StartFileName cannot be within Prefix, so the listing is empty
+func (o *ListOptions) outsidePrefix() bool {
+	return o.Prefix != "" && o.StartFileName != "" && !strings.HasPrefix(o.StartFileName, o.Prefix)
+}
+
 // ListFileNames Lists the names of all files in a bucket, starting at a given name
 func (b *B2) ListFileNames(bucketID string, startFileName string, maxFileCount int) ([]FileName, string, error) {
+	return b.ListFileNamesWithOptions(bucketID, &ListOptions{
+		StartFileName: startFileName,
+		MaxFileCount:  maxFileCount,
+	})
+}
+
+// ListFileNamesWithOptions lists the names of the files in a bucket like ListFileNames, narrowed by opts. StartFileID is not used
+func (b *B2) ListFileNamesWithOptions(bucketID string, opts *ListOptions) ([]FileName, string, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+
+	maxFileCount, err := b.clampMaxFileCount(opts.MaxFileCount)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if opts.outsidePrefix() {
+		return nil, "", nil
+	}
+
 	data, err := json.Marshal(struct {
 		BucketID      string `json:"bucketId"`
 		StartFileName string `json:"startFileName,omitempty"`
 		MaxFileCount  int    `json:"maxFileCount,omitempty"`
+		Prefix        string `json:"prefix,omitempty"`
+		Delimiter     string `json:"delimiter,omitempty"`
 	}{
 		BucketID:      bucketID,
-		StartFileName: startFileName,
+		StartFileName: opts.StartFileName,
 		MaxFileCount:  maxFileCount,
+		Prefix:        opts.Prefix,
+		Delimiter:     opts.Delimiter,
 	})
 	if err != nil {
 		return nil, "", err
@@ -400,7 +1525,7 @@ func (b *B2) ListFileNames(bucketID string, startFileName string, maxFileCount i
 	}
 
 	req.Header.Add("Authorization", b.AuthToken)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, "", err
 	}
@@ -409,30 +1534,85 @@ func (b *B2) ListFileNames(bucketID string, startFileName string, maxFileCount i
 		Files        []FileName `json:"files"`
 		NextFileName string     `json:"nextFileName"`
 	}{}
-	err = readResp(resp, list)
+	err = b.readResp(resp, list)
 	if err != nil {
 		return nil, "", err
 	}
 
 	for i := range list.Files {
-		list.Files[i].conn = b
+		list.Files[i].conn = b.base()
 	}
 
 	return list.Files, list.NextFileName, nil
 }
 
+// latestFileName finds the latest visible version of exactly fileName, returning ErrNotFound if it is hidden or absent
+func (b *B2) latestFileName(bucketID string, fileName string) (*FileName, error) {
+	files, _, err := b.ListFileNamesWithOptions(bucketID, &ListOptions{
+		Prefix:        fileName,
+		StartFileName: fileName,
+		MaxFileCount:  1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the prefix also matches longer names, which are listed when fileName itself does not exist
+	if len(files) == 0 || files[0].Name != fileName || files[0].Action != ActionUpload {
+		return nil, ErrNotFound
+	}
+
+	return &files[0], nil
+}
+
+// LatestFileID finds the file ID of the latest visible version of exactly fileName, returning ErrNotFound if it is hidden or absent
+func (b *B2) LatestFileID(bucketID string, fileName string) (string, error) {
+	latest, err := b.latestFileName(bucketID, fileName)
+	if err != nil {
+		return "", err
+	}
+
+	return latest.ID, nil
+}
+
 // ListFileVersions lists all of the versions of all of the files contained in one bucket, in alphabetical order by file name, and by reverse of date/time uploaded for versions of files with the same name
 func (b *B2) ListFileVersions(bucketID string, startFileName string, startFileID string, maxFileCount int) ([]FileName, string, string, error) {
+	return b.ListFileVersionsWithOptions(bucketID, &ListOptions{
+		StartFileName: startFileName,
+		StartFileID:   startFileID,
+		MaxFileCount:  maxFileCount,
+	})
+}
+
+// ListFileVersionsWithOptions lists the versions of the files contained in one bucket like ListFileVersions, narrowed by opts
+func (b *B2) ListFileVersionsWithOptions(bucketID string, opts *ListOptions) ([]FileName, string, string, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+
+	maxFileCount, err := b.clampMaxFileCount(opts.MaxFileCount)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if opts.outsidePrefix() {
+		return nil, "", "", nil
+	}
+
 	data, err := json.Marshal(struct {
 		BucketID      string `json:"bucketId"`
 		StartFileName string `json:"startFileName,omitempty"`
 		StartFileID   string `json:"startFileId,omitempty"`
 		MaxFileCount  int    `json:"maxFileCount,omitempty"`
+		Prefix        string `json:"prefix,omitempty"`
+		Delimiter     string `json:"delimiter,omitempty"`
 	}{
 		BucketID:      bucketID,
-		StartFileName: startFileName,
-		StartFileID:   startFileID,
+		StartFileName: opts.StartFileName,
+		StartFileID:   opts.StartFileID,
 		MaxFileCount:  maxFileCount,
+		Prefix:        opts.Prefix,
+		Delimiter:     opts.Delimiter,
 	})
 	if err != nil {
 		return nil, "", "", err
@@ -444,7 +1624,7 @@ func (b *B2) ListFileVersions(bucketID string, startFileName string, startFileID
 	}
 
 	req.Header.Add("Authorization", b.AuthToken)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, "", "", err
 	}
@@ -454,13 +1634,13 @@ func (b *B2) ListFileVersions(bucketID string, startFileName string, startFileID
 		NextFileID   string     `json:"nextFileId"`
 		NextFileName string     `json:"nextFileName"`
 	}{}
-	err = readResp(resp, list)
+	err = b.readResp(resp, list)
 	if err != nil {
 		return nil, "", "", err
 	}
 
 	for i := range list.Files {
-		list.Files[i].conn = b
+		list.Files[i].conn = b.base()
 	}
 
 	return list.Files, list.NextFileID, list.NextFileName, nil
@@ -481,13 +1661,13 @@ func (b *B2) GetFileInfo(fileID string) (*FileInfo, error) {
 	}
 
 	req.Header.Add("Authorization", b.AuthToken)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	info := &FileInfo{conn: b}
-	err = readResp(resp, info)
+	info := &FileInfo{conn: b.base()}
+	err = b.readResp(resp, info)
 	if err != nil {
 		return nil, err
 	}
@@ -495,8 +1675,19 @@ func (b *B2) GetFileInfo(fileID string) (*FileInfo, error) {
 	return info, nil
 }
 
-// HideFile hides a file so that downloading by name will not find the file, but previous versions of the file are still stored. See File Versions about what it means to hide a file
+// HideFile hides a file so that downloading by name will not find the file, but previous versions of the file are still stored. See File Versions about what it means to hide a file.
+// The returned FileName is the hide marker: its ID is the marker's own file ID, its Action ActionHide and its Timestamp when it was hidden
 func (b *B2) HideFile(bucketID string, fileName string) (*FileName, error) {
+	err := b.checkNamePrefix(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.DryRun {
+		b.plan("b2_hide_file", map[string]string{"bucketId": bucketID, "fileName": fileName})
+		return &FileName{Name: fileName, Action: ActionHide, conn: b.base()}, nil
+	}
+
 	data, err := json.Marshal(map[string]string{
 		"bucketId": bucketID,
 		"fileName": fileName,
@@ -511,16 +1702,27 @@ func (b *B2) HideFile(bucketID string, fileName string) (*FileName, error) {
 	}
 
 	req.Header.Add("Authorization", b.AuthToken)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	info := &FileName{conn: b}
-	err = readResp(resp, info)
+	info := &FileName{conn: b.base()}
+	err = b.readResp(resp, info)
 	if err != nil {
 		return nil, err
 	}
 
 	return info, nil
 }
+
+// HideFileIfVisible hides a file like HideFile, but a file that is already hidden or does not exist is a success.
+// The returned FileName is nil when nothing had to be hidden
+func (b *B2) HideFileIfVisible(bucketID string, fileName string) (*FileName, error) {
+	hidden, err := b.HideFile(bucketID, fileName)
+	if errors.Is(err, ErrAlreadyHidden) || errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+
+	return hidden, err
+}
@@ -0,0 +1,202 @@
+package b2
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestB2 returns a B2 authorized against a test server answering with handler, and closes the server when the test ends
+func newTestB2(t *testing.T, handler http.HandlerFunc) *B2 {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &B2{
+		AccountID:   "account",
+		APIUrl:      server.URL,
+		DownloadURL: server.URL,
+		AuthToken:   "token",
+	}
+}
+
+func TestDownloadShortRead(t *testing.T) {
+	conn := newTestB2(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Header().Set("X-Bz-File-Id", "id")
+		w.Header().Set("X-Bz-File-Name", "name")
+		w.Write([]byte(strings.Repeat("a", 10)))
+	})
+
+	var output bytes.Buffer
+	_, err := conn.DownloadFileByID("id", &output)
+	if !errors.Is(err, ErrShortRead) {
+		t.Fatalf("expected ErrShortRead, got %v", err)
+	}
+}
+
+// privateBucket answers downloads by name only when they carry the test token, with a bodiless 401 otherwise
+func privateBucket(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("X-Bz-File-Id", "id")
+	w.Header().Set("X-Bz-File-Name", "name")
+	w.Write([]byte("content"))
+}
+
+func TestDownloadFileByNamePrivateBucket(t *testing.T) {
+	conn := newTestB2(t, privateBucket)
+
+	var output bytes.Buffer
+	info, err := conn.DownloadFileByName("bucket", "name", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output.String() != "content" || info.Name != "name" {
+		t.Fatalf("unexpected download %q of %q", output.String(), info.Name)
+	}
+}
+
+func TestDownloadFileByNameUnauthorized(t *testing.T) {
+	conn := newTestB2(t, privateBucket)
+	conn.AuthToken = ""
+
+	var output bytes.Buffer
+	_, err := conn.DownloadFileByName("bucket", "name", &output)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+
+	_, err = conn.GetFileInfoByName("bucket", "name")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized for a bodiless HEAD 401, got %v", err)
+	}
+}
+
+func TestDownloadNotFound(t *testing.T) {
+	bodies := map[string]string{
+		"json":  `{"code":"not_found","message":"file not found","status":404}`,
+		"html":  "<html>Not Found</html>",
+		"empty": "",
+	}
+
+	for kind, body := range bodies {
+		body := body
+		t.Run(kind, func(t *testing.T) {
+			conn := newTestB2(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(body))
+			})
+
+			var output bytes.Buffer
+			_, err := conn.DownloadFileByName("bucket", "missing", &output)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("download by name: expected ErrNotFound, got %v", err)
+			}
+
+			_, err = conn.DownloadFileByID("missing", &output)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("download by ID: expected ErrNotFound, got %v", err)
+			}
+
+			_, err = conn.GetFileInfoByName("bucket", "missing")
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("HEAD: expected ErrNotFound, got %v", err)
+			}
+
+			_, err = conn.GetFileInfo("missing")
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("get file info: expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadFileNamedLikeAPICall(t *testing.T) {
+	conn := newTestB2(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("X-Bz-File-Id", "id")
+		w.Header().Set("X-Bz-File-Name", "b2_backup.tar")
+		w.Write([]byte("content"))
+	})
+	conn.MetadataTimeout = 10 * time.Millisecond
+
+	var output bytes.Buffer
+	_, err := conn.DownloadFileByName("bucket", "b2_backup.tar", &output)
+	if err != nil {
+		t.Fatalf("download of a file named like an API call is bounded by MetadataTimeout: %v", err)
+	}
+
+	if output.String() != "content" {
+		t.Fatalf("unexpected download %q", output.String())
+	}
+}
+
+// fakeB2 records the large files and uploads of a test server behaving like B2, which refuses to finish a large file of less than 2 parts
+type fakeB2 struct {
+	mu        sync.Mutex
+	started   int
+	cancelled int
+	parts     int
+	uploads   map[string][]byte
+}
+
+// newFakeB2 returns a B2 authorized against a fakeB2, and the fakeB2
+func newFakeB2(t *testing.T) (*B2, *fakeB2) {
+	t.Helper()
+
+	fake := &fakeB2{uploads: map[string][]byte{}}
+	return newTestB2(t, fake.serveHTTP), fake
+}
+
+func (f *fakeB2) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	uploadURL := "http://" + r.Host + APIsuffix
+
+	switch requestEndpoint(r) {
+	case "b2_start_large_file":
+		f.started++
+		w.Write([]byte(`{"fileId":"large","fileName":"name"}`))
+	case "b2_cancel_large_file":
+		f.cancelled++
+		w.Write([]byte(`{"fileId":"large","fileName":"name"}`))
+	case "b2_get_upload_part_url":
+		w.Write([]byte(`{"fileId":"large","uploadUrl":"` + uploadURL + `/b2_upload_part/large","authorizationToken":"part"}`))
+	case "b2_upload_part":
+		f.parts++
+		w.Write([]byte(`{"fileId":"large","partNumber":` + r.Header.Get("X-Bz-Part-Number") + `,"contentLength":` + strconv.Itoa(len(body)) + `,"contentSha1":"` + r.Header.Get("X-Bz-Content-Sha1") + `"}`))
+	case "b2_finish_large_file":
+		if f.parts < 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":400,"code":"bad_request","message":"large files must have at least 2 parts"}`))
+			return
+		}
+
+		f.uploads["large"] = nil
+		w.Write([]byte(`{"fileId":"large","fileName":"name","contentSha1":"none"}`))
+	case "b2_get_upload_url":
+		w.Write([]byte(`{"bucketId":"bucket","uploadUrl":"` + uploadURL + `/b2_upload_file/bucket","authorizationToken":"upload"}`))
+	case "b2_upload_file":
+		name, _ := url.QueryUnescape(r.Header.Get("X-Bz-File-Name"))
+		f.uploads[name] = body
+		w.Write([]byte(`{"fileId":"small","fileName":"` + name + `","contentLength":` + strconv.Itoa(len(body)) + `,"contentSha1":"` + r.Header.Get("X-Bz-Content-Sha1") + `"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
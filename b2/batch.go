@@ -0,0 +1,76 @@
+package b2
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultConcurrency the amount of workers used by batch operations when B2.Concurrency is not set
+const DefaultConcurrency = 8
+
+// DeleteError failure to delete one file version of a batch
+type DeleteError struct {
+	File FileName
+	Err  error
+}
+
+func (e *DeleteError) Error() string {
+	return fmt.Sprintf("failed to delete '%s' version '%s': %s", e.File.Name, e.File.ID, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DeleteError) Unwrap() error {
+	return e.Err
+}
+
+// concurrency the amount of workers batch operations should use
+func (b *B2) concurrency() int {
+	if b.Concurrency > 0 {
+		return b.Concurrency
+	}
+
+	return DefaultConcurrency
+}
+
+// DeleteFileVersions deletes many file versions concurrently with up to Concurrency workers.
+// Every failure is reported as a *DeleteError instead of stopping the batch, both results keep the order of files
+func (b *B2) DeleteFileVersions(files []FileName) ([]FileName, []error) {
+	conn, cancel := b.operation()
+	defer cancel()
+
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				_, err := conn.DeleteFileVersion(files[index].Name, files[index].ID)
+				if err != nil {
+					errs[index] = &DeleteError{File: files[index], Err: err}
+				}
+			}
+		}()
+	}
+
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var deleted []FileName
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+			continue
+		}
+
+		deleted = append(deleted, files[i])
+	}
+
+	return deleted, failed
+}
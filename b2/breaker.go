@@ -0,0 +1,77 @@
+package b2
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen the circuit breaker saw too many consecutive failures, requests are refused until its cooldown is over
+var ErrCircuitOpen = errors.New("Too many consecutive failures, B2 requests are paused")
+
+// CircuitBreaker stops sending requests after consecutive failures, connection errors and 5xx statuses, for a cooldown.
+// Once it is over a single trial request is let through: its success closes the circuit again, its failure reopens it.
+// It may be shared between several B2
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+// NewCircuitBreaker creates a CircuitBreaker opening after failures consecutive failures, for cooldown
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	if failures < 1 {
+		failures = 1
+	}
+
+	return &CircuitBreaker{threshold: failures, cooldown: cooldown}
+}
+
+// allow tells whether a request may be sent, marking it as the trial request when the cooldown is over
+func (c *CircuitBreaker) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failures < c.threshold {
+		return nil
+	}
+
+	if c.trial || time.Since(c.openedAt) < c.cooldown {
+		return ErrCircuitOpen
+	}
+
+	c.trial = true
+	return nil
+}
+
+// success closes the circuit after a request B2 answered properly
+func (c *CircuitBreaker) success() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.trial = false
+	c.failures = 0
+}
+
+// failure counts a failed request, opening the circuit once there are enough in a row
+func (c *CircuitBreaker) failure() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.trial = false
+	c.failures++
+	if c.failures >= c.threshold {
+		c.openedAt = time.Now()
+	}
+}
+
+// abandon lets another trial request through after one that ended without telling anything about B2, such as when cancelled
+func (c *CircuitBreaker) abandon() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.trial = false
+}
@@ -1,18 +1,95 @@
 package b2
 
 import (
+	"context"
+	"errors"
 	"io"
+	"net/http"
 	"time"
 )
 
+// BucketTypePublic anybody may download the files of the bucket
+const BucketTypePublic = "allPublic"
+
+// BucketTypePrivate downloading the files of the bucket requires authorization
+const BucketTypePrivate = "allPrivate"
+
+// BucketTypeSnapshot bucket holding the snapshots of B2's web backups, only B2 creates them
+const BucketTypeSnapshot = "snapshot"
+
+// ErrInvalidBucketType the bucket type is not one of the known BucketType constants
+var ErrInvalidBucketType = errors.New("Invalid bucket type")
+
+// validateBucketType rejects unknown bucket types unless AllowUnknownBucketTypes is set
+func (b *B2) validateBucketType(bucketType string) error {
+	switch bucketType {
+	case BucketTypePublic, BucketTypePrivate, BucketTypeSnapshot:
+		return nil
+	}
+
+	if b.AllowUnknownBucketTypes {
+		return nil
+	}
+
+	return ErrInvalidBucketType
+}
+
+// listPageSize the amount of files requested per page when listing a whole bucket or prefix
+const listPageSize = 1000
+
+// DefaultMaxListResults the most files accumulated by a listing helper when B2.MaxListResults is not set
+const DefaultMaxListResults = 100000
+
 // Bucket B2 bucket type
 type Bucket struct {
-	AccountID string `json:"accountId"`
-	ID        string `json:"bucketId"`
-	Name      string `json:"bucketName"`
-	Type      string `json:"bucketType"`
-	conn      *B2
-	upload    *Upload
+	AccountID                string                    `json:"accountId"`
+	ID                       string                    `json:"bucketId"`
+	Name                     string                    `json:"bucketName"`
+	Type                     string                    `json:"bucketType"`
+	Info                     map[string]string         `json:"bucketInfo"`
+	ReplicationConfiguration *ReplicationConfiguration `json:"replicationConfiguration"`
+	LifecycleRules           []LifecycleRule           `json:"lifecycleRules"`
+	Revision                 int                       `json:"revision"`
+
+	// Options features enabled on the bucket, such as "s3" when the S3 compatible API may be used with it
+	Options []string `json:"options"`
+
+	// DefaultFileInfo info added to every file uploaded through this bucket, keys given to an upload win.
+	// The InfoKey constants are keys B2 serves back as headers on download, such as a default Cache-Control
+	DefaultFileInfo map[string]string `json:"-"`
+
+	conn *B2
+}
+
+// BucketOptions optional settings when creating or updating a bucket, nil fields are left unset
+type BucketOptions struct {
+	Info                     map[string]string
+	ReplicationConfiguration *ReplicationConfiguration
+
+	// LifecycleRules replace the rules of the bucket, nil leaves them unset and an empty list removes them all
+	LifecycleRules []LifecycleRule
+
+	// IfRevisionIs only applies an update if the bucket is still at this revision, failing with ErrConflict otherwise.
+	// Zero updates whatever the revision, it is ignored when creating a bucket
+	IfRevisionIs int
+}
+
+// fileInfo merges info over DefaultFileInfo, info itself is left untouched
+func (b *Bucket) fileInfo(info map[string]string) map[string]string {
+	if len(b.DefaultFileInfo) == 0 {
+		return info
+	}
+
+	merged := make(map[string]string, len(b.DefaultFileInfo)+len(info))
+	for name, value := range b.DefaultFileInfo {
+		merged[name] = value
+	}
+
+	for name, value := range info {
+		merged[name] = value
+	}
+
+	return merged
 }
 
 // Delete deletes this bucket
@@ -23,7 +100,12 @@ func (b *Bucket) Delete() error {
 
 // Update updates this bucket
 func (b *Bucket) Update(bucketType string) error {
-	bucket, err := b.conn.UpdateBucket(b.ID, bucketType)
+	return b.UpdateWithOptions(bucketType, nil)
+}
+
+// UpdateWithOptions updates this bucket with the settings of opts, set opts.IfRevisionIs to b.Revision to not overwrite concurrent updates
+func (b *Bucket) UpdateWithOptions(bucketType string, opts *BucketOptions) error {
+	bucket, err := b.conn.UpdateBucketWithOptions(b.ID, bucketType, opts)
 	if err != nil {
 		return err
 	}
@@ -32,6 +114,11 @@ func (b *Bucket) Update(bucketType string) error {
 	b.ID = bucket.ID
 	b.Name = bucket.Name
 	b.Type = bucket.Type
+	b.Info = bucket.Info
+	b.ReplicationConfiguration = bucket.ReplicationConfiguration
+	b.LifecycleRules = bucket.LifecycleRules
+	b.Revision = bucket.Revision
+	b.Options = bucket.Options
 
 	return nil
 }
@@ -46,20 +133,427 @@ func (b *Bucket) ListFileVersions(startFileName string, startFileID string, maxF
 	return b.conn.ListFileVersions(b.ID, startFileName, startFileID, maxFileCount)
 }
 
+// IsEmpty tells whether the bucket holds no file version, hide marker nor unfinished large file, which B2 requires to delete it.
+// It lists at most one of each instead of walking the bucket
+func (b *Bucket) IsEmpty() (bool, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	versions, _, _, err := conn.ListFileVersions(b.ID, "", "", 1)
+	if err != nil {
+		return false, err
+	}
+
+	if len(versions) > 0 {
+		return false, nil
+	}
+
+	unfinished, _, err := conn.ListUnfinishedLargeFiles(b.ID, "", 1)
+	if err != nil {
+		return false, err
+	}
+
+	return len(unfinished) == 0, nil
+}
+
+// AllFileNames lists the names of every visible file starting with prefix, following every page.
+// ErrTooManyResults is returned rather than accumulating more than MaxListResults files
+func (b *Bucket) AllFileNames(prefix string) ([]FileName, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	maxResults := conn.MaxListResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxListResults
+	}
+
+	var all []FileName
+	opts := &ListOptions{
+		Prefix:       prefix,
+		MaxFileCount: listPageSize,
+	}
+
+	for {
+		files, nextFileName, err := conn.ListFileNamesWithOptions(b.ID, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(all)+len(files) > maxResults {
+			return nil, ErrTooManyResults
+		}
+
+		all = append(all, files...)
+
+		if nextFileName == "" {
+			return all, nil
+		}
+
+		opts.StartFileName = nextFileName
+	}
+}
+
+// ListGroupedVersions lists every version of the files whose name starts with prefix, grouped by file name.
+// Versions within a group keep B2's order, newest first
+func (b *Bucket) ListGroupedVersions(prefix string) (map[string][]FileName, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	groups := map[string][]FileName{}
+	opts := &ListOptions{
+		Prefix:       prefix,
+		MaxFileCount: listPageSize,
+	}
+
+	for {
+		files, nextFileID, nextFileName, err := conn.ListFileVersionsWithOptions(b.ID, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		// a name's versions may span several pages, appending keeps them in order
+		for _, file := range files {
+			groups[file.Name] = append(groups[file.Name], file)
+		}
+
+		if nextFileName == "" {
+			return groups, nil
+		}
+
+		opts.StartFileName = nextFileName
+		opts.StartFileID = nextFileID
+	}
+}
+
+// FileVersionsForName lists every version of exactly fileName, newest first, including hide markers.
+// The listing starts at fileName and stops at the first other name, so it costs one transaction per listPageSize versions
+func (b *Bucket) FileVersionsForName(fileName string) ([]FileName, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	var versions []FileName
+	opts := &ListOptions{
+		Prefix:        fileName,
+		StartFileName: fileName,
+		MaxFileCount:  listPageSize,
+	}
+
+	for {
+		files, nextFileID, nextFileName, err := conn.ListFileVersionsWithOptions(b.ID, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		// names are listed in order, the versions of fileName come first and any other name ends them
+		for _, file := range files {
+			if file.Name != fileName {
+				return versions, nil
+			}
+
+			versions = append(versions, file)
+		}
+
+		if nextFileName != fileName {
+			return versions, nil
+		}
+
+		opts.StartFileID = nextFileID
+	}
+}
+
+// DownloadFileVersion downloads one specific version of a file of this bucket, such as one listed by ListFileVersions
+func (b *Bucket) DownloadFileVersion(file FileName, output io.Writer) (*FileInfo, error) {
+	if file.conn == nil {
+		file.conn = b.conn
+	}
+
+	return file.Download(output)
+}
+
 // HideFile hides a file so that downloading by name will not find the file, but previous versions of the file are still stored. See File Versions about what it means to hide a file
 func (b *Bucket) HideFile(fileName string) (*FileName, error) {
 	return b.conn.HideFile(b.ID, fileName)
 }
 
+// LatestFileID finds the file ID of the latest visible version of exactly fileName, see B2.LatestFileID
+func (b *Bucket) LatestFileID(fileName string) (string, error) {
+	return b.conn.LatestFileID(b.ID, fileName)
+}
+
+// HideFileIfVisible hides a file unless it is already hidden or does not exist, see B2.HideFileIfVisible
+func (b *Bucket) HideFileIfVisible(fileName string) (*FileName, error) {
+	return b.conn.HideFileIfVisible(b.ID, fileName)
+}
+
+// PrepareUpload gets an upload URL of this bucket for a client to upload with directly, without sending the content
+// through this program. The URL is not pooled since the client uses it
+func (b *Bucket) PrepareUpload() (*PreparedUpload, error) {
+	upload, err := b.conn.GetUploadURL(b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]string, len(preparedUploadHeaders))
+	copy(headers, preparedUploadHeaders)
+
+	return &PreparedUpload{
+		BucketID:        upload.BucketID,
+		UploadURL:       upload.UploadURL,
+		AuthToken:       upload.AuthToken,
+		RequiredHeaders: headers,
+	}, nil
+}
+
 // UploadFile uploads one file to B2
 func (b *Bucket) UploadFile(data io.Reader, fileName string, fileSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string) (*FileInfo, error) {
-	if b.upload == nil {
+	return b.uploadFile(data, fileName, fileSize, contentType, sha1, mtime, info, nil)
+}
+
+// UploadFile uploads one file to the bucket bucketID with a pooled upload URL, retrying it like Bucket.UploadFile
+func (b *B2) UploadFile(bucketID string, data io.Reader, fileName string, fileSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string) (*FileInfo, error) {
+	bucket := &Bucket{AccountID: b.AccountID, ID: bucketID, conn: b}
+	return bucket.UploadFile(data, fileName, fileSize, contentType, sha1, mtime, info)
+}
+
+// uploadFile uploads one file to B2 like UploadFile, with header added to the upload request
+func (b *Bucket) uploadFile(data io.Reader, fileName string, fileSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string, header http.Header) (*FileInfo, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	info = b.fileInfo(info)
+
+	// make the data readable again in case it has to be sent again
+	rewind := func() error { return ErrNotSeekable }
+	if conn.MaxRetries > 0 {
 		var err error
-		b.upload, err = b.conn.GetUploadURL(b.ID)
+		data, rewind, err = conn.replayable(data, fileSize)
 		if err != nil {
 			return nil, err
 		}
+	} else if seeker, ok := data.(io.ReadSeeker); ok {
+		if _, replay, err := conn.replayable(seeker, fileSize); err == nil {
+			rewind = replay
+		}
+	}
+
+	for retry := 1; ; retry++ {
+		fileInfo, err := conn.uploadPooled(b.ID, data, fileName, fileSize, contentType, sha1, mtime, info, header)
+		if err == nil || !retryable(err) {
+			return fileInfo, err
+		}
+
+		// an expired token is retried once even without MaxRetries, the URL was dropped so a fresh one is used
+		if retry > conn.MaxRetries && !(retry == 1 && errors.Is(err, ErrExpiredAuthToken)) {
+			return nil, err
+		}
+
+		if rewindErr := rewind(); rewindErr != nil {
+			return nil, err
+		}
+
+		if sleepErr := conn.sleep(conn.retryDelay(err, retry)); sleepErr != nil {
+			return nil, err
+		}
+
+		conn.observeRetry("b2_upload_file")
+	}
+}
+
+// UploadFileSha1Digest uploads one file to B2 like UploadFile, with its SHA1 given as the raw digest rather than hex
+func (b *Bucket) UploadFileSha1Digest(data io.Reader, fileName string, fileSize int64, contentType string, digest []byte, mtime *time.Time, info map[string]string) (*FileInfo, error) {
+	sha1, err := Sha1HexDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.UploadFile(data, fileName, fileSize, contentType, sha1, mtime, info)
+}
+
+// uploadPooled uploads one file with an upload URL from the pool, returning the URL to the pool only if it worked
+func (b *B2) uploadPooled(bucketID string, data io.Reader, fileName string, fileSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string, header http.Header) (*FileInfo, error) {
+	upload, err := b.acquireUpload(bucketID)
+	if err != nil {
+		return nil, err
+	}
+
+	withConn := *upload
+	withConn.conn = b
+
+	fileInfo, err := withConn.uploadFile(data, fileName, fileSize, contentType, sha1, mtime, info, header)
+	if err != nil {
+		// the failed URL is never put back in the pool, nor are the ones of its pod when it is unavailable
+		if podUnavailable(err) {
+			b.discardUploadHost(upload)
+		}
+
+		return nil, err
+	}
+
+	b.releaseUpload(upload)
+
+	return fileInfo, nil
+}
+
+// UploadFileStreamingSha1 uploads one file to B2 from data that cannot be read twice, the SHA1 is computed while sending and appended to the content
+func (b *Bucket) UploadFileStreamingSha1(data io.Reader, fileName string, fileSize int64, contentType string, mtime *time.Time, info map[string]string) (*FileInfo, error) {
+	return b.UploadFile(newSha1AtEndReader(data), fileName, fileSize+sha1HexLength, contentType, Sha1AtEnd, mtime, info)
+}
+
+// UploadIfChanged uploads r as fileName unless the latest version of fileName already has the same SHA1.
+// It returns the new or existing file and whether an upload happened
+func (b *Bucket) UploadIfChanged(fileName string, r io.ReadSeeker, opts *PutOptions) (*FileInfo, bool, error) {
+	if opts == nil {
+		opts = &PutOptions{}
+	}
+
+	existing, err := b.conn.GetFileInfoByName(b.Name, fileName)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+
+	sha1, md5, size, err := hashSums(r, opts.ComputeMd5 || opts.SendContentMd5)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if existing != nil && existing.ContentSha1() == sha1 {
+		return existing, false, nil
+	}
+
+	info, err := b.put(r, fileName, size, sha1, md5, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return info, true, nil
+}
+
+// UploadIfAbsent uploads r as fileName unless fileName already has a visible version, which is returned instead.
+// It returns the new or existing file and whether an upload happened. B2 has no conditional upload, so a version
+// uploaded by someone else between the check and the upload is not detected
+func (b *Bucket) UploadIfAbsent(fileName string, r io.ReadSeeker, opts *PutOptions) (*FileInfo, bool, error) {
+	existing, err := b.conn.GetFileInfoByName(b.Name, fileName)
+	if err == nil {
+		return existing, false, nil
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+
+	info, err := b.Put(fileName, r, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return info, true, nil
+}
+
+// UploadContentAddressed uploads r under the name derived from its SHA1 by name, unless a file of that name with the same
+// SHA1 is already stored, as in a content addressed store. It returns the new or existing file and whether an upload happened
+func (b *Bucket) UploadContentAddressed(r io.ReadSeeker, name func(sha1 string) string, opts *PutOptions) (*FileInfo, bool, error) {
+	if opts == nil {
+		opts = &PutOptions{}
+	}
+
+	sha1, md5, size, err := hashSums(r, opts.ComputeMd5 || opts.SendContentMd5)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fileName := name(sha1)
+	existing, err := b.conn.GetFileInfoByName(b.Name, fileName)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+
+	if existing != nil && existing.ContentSha1() == sha1 {
+		return existing, false, nil
+	}
+
+	info, err := b.put(r, fileName, size, sha1, md5, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return info, true, nil
+}
+
+// Put uploads r as fileName, its size and SHA1 (and MD5 with opts.ComputeMd5) are computed in one pass over r before uploading
+func (b *Bucket) Put(fileName string, r io.ReadSeeker, opts *PutOptions) (*FileInfo, error) {
+	if opts == nil {
+		opts = &PutOptions{}
+	}
+
+	sha1, md5, size, err := hashSums(r, opts.ComputeMd5 || opts.SendContentMd5)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.put(r, fileName, size, sha1, md5, opts)
+}
+
+// put uploads r with its precomputed hashes and the settings of opts
+func (b *Bucket) put(r io.ReadSeeker, fileName string, size int64, sha1 string, md5 string, opts *PutOptions) (*FileInfo, error) {
+	info, err := opts.info()
+	if err != nil {
+		return nil, err
+	}
+
+	if md5 != "" && opts.ComputeMd5 {
+		info = withInfo(info, Md5InfoKey, md5)
+	}
+
+	var header http.Header
+	contentMd5, err := opts.contentMd5(md5)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentMd5 != "" {
+		header = http.Header{"Content-Md5": {contentMd5}}
+	}
+
+	return b.uploadFile(r, fileName, size, opts.ContentType, sha1, opts.Mtime, info, header)
+}
+
+// waitForBucketMinDelay the first delay between readiness checks of WaitForBucket when B2.Backoff is not set
+const waitForBucketMinDelay = 250 * time.Millisecond
+
+// waitForBucketMaxDelay the longest delay between readiness checks of WaitForBucket
+const waitForBucketMaxDelay = 5 * time.Second
+
+// WaitForBucket polls for an upload URL of bucketID with Backoff until one is handed out, or timeout elapses.
+// It returns at once if the bucket is already usable, and gives up early on authorization errors.
+// The upload URL obtained is kept in the pool for the next upload
+func (b *B2) WaitForBucket(bucketID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(b.context(), timeout)
+	defer cancel()
+
+	conn := b.WithContext(ctx)
+	backoff := b.Backoff
+	if backoff == nil {
+		backoff = &ExponentialBackoff{Min: waitForBucketMinDelay, Max: waitForBucketMaxDelay}
+	}
+
+	for attempt := 1; ; attempt++ {
+		upload, err := conn.GetUploadURL(bucketID)
+		if err == nil {
+			b.releaseUpload(upload)
+			return nil
+		}
+
+		if errors.Is(err, ErrBadAuthToken) || errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrExpiredAuthToken) {
+			return err
+		}
+
+		if conn.sleep(backoff.NextDelay(attempt)) != nil {
+			return err
+		}
 	}
+}
 
-	return b.upload.UploadFile(data, fileName, fileSize, contentType, sha1, mtime, info)
+// WaitUntilUsable waits until uploads to this bucket work, see WaitForBucket
+func (b *Bucket) WaitUntilUsable(timeout time.Duration) error {
+	return b.conn.WaitForBucket(b.ID, timeout)
 }
@@ -0,0 +1,59 @@
+package b2
+
+import (
+	"io"
+	"sync"
+)
+
+// DefaultCopyBufferSize the size of the buffers copying transfers when B2.CopyBufferSize is not set
+const DefaultCopyBufferSize = 32 * 1024
+
+// bufferPools reusable copy buffers, one *sync.Pool per buffer size, a sync.Map so copies do not contend on a lock
+var bufferPools sync.Map
+
+// copyBufferSize the size of the buffers copying transfers
+func (b *B2) copyBufferSize() int {
+	if b != nil && b.CopyBufferSize > 0 {
+		return b.CopyBufferSize
+	}
+
+	return DefaultCopyBufferSize
+}
+
+// bufferPool the pool of buffers of size bytes
+func bufferPool(size int) *sync.Pool {
+	pool, ok := bufferPools.Load(size)
+	if !ok {
+		pool, _ = bufferPools.LoadOrStore(size, &sync.Pool{New: func() interface{} {
+			buf := make([]byte, size)
+			return &buf
+		}})
+	}
+
+	return pool.(*sync.Pool)
+}
+
+// copyBuffered copies src to dst like io.Copy, with a buffer from the pool instead of a new one per call
+func (b *B2) copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	return copyPooled(dst, src, b.copyBufferSize())
+}
+
+// copyPooled copies src to dst with a pooled buffer of size bytes
+func copyPooled(dst io.Writer, src io.Reader, size int) (int64, error) {
+	pool := bufferPool(size)
+	buf := pool.Get().(*[]byte)
+	defer pool.Put(buf)
+
+	// io.CopyBuffer ignores the buffer when dst is an io.ReaderFrom or src an io.WriterTo, such as *os.File and *bytes.Buffer
+	return io.CopyBuffer(writerOnly{dst}, readerOnly{src}, *buf)
+}
+
+// writerOnly hides every method of a writer but Write
+type writerOnly struct {
+	io.Writer
+}
+
+// readerOnly hides every method of a reader but Read
+type readerOnly struct {
+	io.Reader
+}
@@ -0,0 +1,49 @@
+package b2
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// benchmarkContent the content copied by the copy benchmarks, the size of a small download
+var benchmarkContent = bytes.Repeat([]byte("b2"), 512*1024)
+
+// copyTarget a download target implementing io.ReaderFrom like *os.File, discarding what it is given
+type copyTarget struct{}
+
+func (copyTarget) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (c copyTarget) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(ioutil.Discard, r)
+}
+
+func BenchmarkCopyBuffered(b *testing.B) {
+	conn := &B2{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkContent)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := conn.copyBuffered(copyTarget{}, readerOnly{bytes.NewReader(benchmarkContent)})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCopy(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkContent)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := io.Copy(writerOnly{copyTarget{}}, readerOnly{bytes.NewReader(benchmarkContent)})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package b2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPrefixNotAllowed the application key is restricted to names starting with Allowed.NamePrefix, which the file name does not
+var ErrPrefixNotAllowed = errors.New("File name is outside of the name prefix the key is restricted to")
+
+// application key capabilities, as accepted by B2 when creating a key
+const (
+	CapabilityListKeys                = "listKeys"
+	CapabilityWriteKeys               = "writeKeys"
+	CapabilityDeleteKeys              = "deleteKeys"
+	CapabilityListBuckets             = "listBuckets"
+	CapabilityListAllBucketNames      = "listAllBucketNames"
+	CapabilityReadBuckets             = "readBuckets"
+	CapabilityWriteBuckets            = "writeBuckets"
+	CapabilityDeleteBuckets           = "deleteBuckets"
+	CapabilityReadBucketEncryption    = "readBucketEncryption"
+	CapabilityWriteBucketEncryption   = "writeBucketEncryption"
+	CapabilityReadBucketRetentions    = "readBucketRetentions"
+	CapabilityWriteBucketRetentions   = "writeBucketRetentions"
+	CapabilityReadBucketReplications  = "readBucketReplications"
+	CapabilityWriteBucketReplications = "writeBucketReplications"
+	CapabilityReadFileRetentions      = "readFileRetentions"
+	CapabilityWriteFileRetentions     = "writeFileRetentions"
+	CapabilityReadFileLegalHolds      = "readFileLegalHolds"
+	CapabilityWriteFileLegalHolds     = "writeFileLegalHolds"
+	CapabilityBypassGovernance        = "bypassGovernance"
+	CapabilityListFiles               = "listFiles"
+	CapabilityReadFiles               = "readFiles"
+	CapabilityShareFiles              = "shareFiles"
+	CapabilityWriteFiles              = "writeFiles"
+	CapabilityDeleteFiles             = "deleteFiles"
+)
+
+// capabilities every known capability, in the order they are listed in errors
+var capabilities = []string{
+	CapabilityListKeys,
+	CapabilityWriteKeys,
+	CapabilityDeleteKeys,
+	CapabilityListBuckets,
+	CapabilityListAllBucketNames,
+	CapabilityReadBuckets,
+	CapabilityWriteBuckets,
+	CapabilityDeleteBuckets,
+	CapabilityReadBucketEncryption,
+	CapabilityWriteBucketEncryption,
+	CapabilityReadBucketRetentions,
+	CapabilityWriteBucketRetentions,
+	CapabilityReadBucketReplications,
+	CapabilityWriteBucketReplications,
+	CapabilityReadFileRetentions,
+	CapabilityWriteFileRetentions,
+	CapabilityReadFileLegalHolds,
+	CapabilityWriteFileLegalHolds,
+	CapabilityBypassGovernance,
+	CapabilityListFiles,
+	CapabilityReadFiles,
+	CapabilityShareFiles,
+	CapabilityWriteFiles,
+	CapabilityDeleteFiles,
+}
+
+// InvalidCapabilityError a capability is not one of the Capability constants
+type InvalidCapabilityError struct {
+	Capability string
+}
+
+func (e *InvalidCapabilityError) Error() string {
+	return fmt.Sprintf("unknown capability '%s', valid capabilities are: %s", e.Capability, strings.Join(capabilities, ", "))
+}
+
+// ValidateCapabilities checks every capability is known, returning an *InvalidCapabilityError for the first one that is not
+func ValidateCapabilities(caps []string) error {
+	for _, capability := range caps {
+		known := false
+		for _, valid := range capabilities {
+			if capability == valid {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return &InvalidCapabilityError{Capability: capability}
+		}
+	}
+
+	return nil
+}
+
+// Allowed what the application key used to authorize may do, as reported by B2 when authorizing
+type Allowed struct {
+	Capabilities []string `json:"capabilities"`
+
+	// the key is restricted to this bucket when BucketID is set, and to the file names starting with NamePrefix when set
+	BucketID   string `json:"bucketId"`
+	BucketName string `json:"bucketName"`
+	NamePrefix string `json:"namePrefix"`
+}
+
+// has tells whether capability is one of the allowed capabilities
+func (a *Allowed) has(capability string) bool {
+	for _, allowed := range a.Capabilities {
+		if allowed == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Can tells whether the key used to authorize has capability. It is assumed to have every capability when B2 did not
+// report them, and a key restricted to a bucket is reported as having its capabilities, see Bucket.Can for that bucket
+func (b *B2) Can(capability string) bool {
+	if b.Allowed == nil {
+		return true
+	}
+
+	return b.Allowed.has(capability)
+}
+
+// Can tells whether the key used to authorize has capability on this bucket, false for a key restricted to another bucket
+func (b *Bucket) Can(capability string) bool {
+	allowed := b.conn.Allowed
+	if allowed == nil {
+		return true
+	}
+
+	if allowed.BucketID != "" && allowed.BucketID != b.ID {
+		return false
+	}
+
+	return allowed.has(capability)
+}
+
+// checkNamePrefix fails with ErrPrefixNotAllowed when the key is restricted to a name prefix fileName lacks
+func (b *B2) checkNamePrefix(fileName string) error {
+	if b == nil || b.Allowed == nil || b.Allowed.NamePrefix == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(fileName, b.Allowed.NamePrefix) {
+		return ErrPrefixNotAllowed
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package b2
+
+import (
+	"io"
+	"net/url"
+	"time"
+)
+
+// B2Client the B2 API calls implemented by *B2, depend on it instead of *B2 to substitute a mock in tests.
+// Every method of *B2 calling the API belongs here, settings such as WithContext or Close do not
+type B2Client interface {
+	CreateBucket(bucketName string, bucketType string) (*Bucket, error)
+	CreateBucketWithOptions(bucketName string, bucketType string, opts *BucketOptions) (*Bucket, error)
+	DeleteBucket(bucketID string) (*Bucket, error)
+	UpdateBucket(bucketID string, bucketType string) (*Bucket, error)
+	UpdateBucketWithOptions(bucketID string, bucketType string, opts *BucketOptions) (*Bucket, error)
+	ListBuckets() ([]Bucket, error)
+	ListBucketsWithOptions(opts *ListBucketsOptions) ([]Bucket, error)
+	GetBucketByName(bucketName string) (*Bucket, error)
+	ListBucketsWithStats(concurrency int) ([]BucketStats, error)
+	AccountUsage() (*Usage, error)
+	Ping() error
+	EnsureBucket(bucketName string, bucketType string) (*Bucket, error)
+	GetUploadURL(bucketID string) (*Upload, error)
+	UploadFile(bucketID string, data io.Reader, fileName string, fileSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string) (*FileInfo, error)
+	WaitForBucket(bucketID string, timeout time.Duration) error
+	DownloadFileByID(fileID string, output io.Writer) (*FileInfo, error)
+	DownloadFileByIDWithParams(fileID string, params url.Values, output io.Writer) (*FileInfo, error)
+	DownloadFileRangeByID(fileID string, start int64, end int64, output io.Writer) (*FileInfo, error)
+	DownloadFileConcurrent(fileID string, w io.WriterAt, concurrency int) (*FileInfo, error)
+	DownloadFileByName(bucketName string, fileName string, output io.Writer) (*FileInfo, error)
+	DownloadFileByNameWithParams(bucketName string, fileName string, params url.Values, output io.Writer) (*FileInfo, error)
+	DownloadFileByNameIfModifiedSince(bucketName string, fileName string, t time.Time, output io.Writer) (*FileInfo, error)
+	DownloadBytesByName(bucketName string, fileName string) ([]byte, *FileInfo, error)
+	DownloadBytesByID(fileID string) ([]byte, *FileInfo, error)
+	GetFileInfo(fileID string) (*FileInfo, error)
+	GetFileInfoByName(bucketName string, fileName string) (*FileInfo, error)
+	ListFileNames(bucketID string, startFileName string, maxFileCount int) ([]FileName, string, error)
+	ListFileNamesWithOptions(bucketID string, opts *ListOptions) ([]FileName, string, error)
+	ListFileVersions(bucketID string, startFileName string, startFileID string, maxFileCount int) ([]FileName, string, string, error)
+	ListFileVersionsWithOptions(bucketID string, opts *ListOptions) ([]FileName, string, string, error)
+	HideFile(bucketID string, fileName string) (*FileName, error)
+	HideFileIfVisible(bucketID string, fileName string) (*FileName, error)
+	LatestFileID(bucketID string, fileName string) (string, error)
+	DeleteFileVersion(fileName string, fileID string) (*FileInfo, error)
+	DeleteFileVersionBypass(fileName string, fileID string, bypassGovernance bool) (*FileInfo, error)
+	DeleteFileVersions(files []FileName) ([]FileName, []error)
+	CopyFile(sourceFileID string, fileName string, opts *CopyOptions) (*FileInfo, error)
+	CopyAcross(dst *B2, dstBucketID string, dstName string, srcFileID string) (*FileInfo, error)
+	StartLargeFile(bucketID string, fileName string, contentType string, info map[string]string) (*LargeFile, error)
+	GetUploadPartURL(fileID string) (*UploadPartURL, error)
+	FinishLargeFile(fileID string, partSha1s []string) (*FileInfo, error)
+	CancelLargeFile(fileID string) (*LargeFile, error)
+	ListUnfinishedLargeFiles(bucketID string, startFileID string, maxFileCount int) ([]*LargeFile, string, error)
+}
+
+// *B2 is the real implementation of B2Client
+var _ B2Client = (*B2)(nil)
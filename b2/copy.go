@@ -0,0 +1,271 @@
+package b2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrSha1Mismatch the SHA1 of transferred content differs from the one B2 has for it
+var ErrSha1Mismatch = errors.New("SHA1 of the content does not match")
+
+// ErrCopyMetadata a content type or file info was given to a copy keeping the metadata of the source file
+var ErrCopyMetadata = errors.New("Content type and file info can only be set with MetadataDirectiveReplace")
+
+// ErrMissingContentType a copy replacing the metadata of the source file was given no content type
+var ErrMissingContentType = errors.New("MetadataDirectiveReplace requires a content type")
+
+// ErrTooFewRequests an operation needs more requests in flight at once than B2.MaxConcurrentRequests allows
+var ErrTooFewRequests = errors.New("Operation needs at least 2 concurrent requests")
+
+// MetadataDirectiveCopy copies the content type and file info of the source file
+const MetadataDirectiveCopy = "COPY"
+
+// MetadataDirectiveReplace replaces the content type and file info with the ones given to the copy
+const MetadataDirectiveReplace = "REPLACE"
+
+// CopyOptions optional parameters for copying a file
+type CopyOptions struct {
+	// DestinationBucketID defaults to the bucket of the source file
+	DestinationBucketID string
+	// Range of bytes to copy, such as "bytes=0-99", defaults to the whole file
+	Range string
+	// MetadataDirective defaults to MetadataDirectiveCopy
+	MetadataDirective string
+	// ContentType and Info may only be set with MetadataDirectiveReplace, which requires ContentType
+	ContentType string
+	Info        map[string]string
+}
+
+// validate enforces the rules of B2 on the metadata directive, which it would otherwise reject with a less helpful error
+func (o *CopyOptions) validate() error {
+	if o.MetadataDirective == MetadataDirectiveReplace {
+		if o.ContentType == "" {
+			return ErrMissingContentType
+		}
+
+		return nil
+	}
+
+	if (o.MetadataDirective == "" || o.MetadataDirective == MetadataDirectiveCopy) && (o.ContentType != "" || o.Info != nil) {
+		return ErrCopyMetadata
+	}
+
+	return nil
+}
+
+// CopyFile creates a new file by copying an existing file server side, without downloading it.
+// The returned FileInfo is the one of the new file, with the content type it got from the source or from opts
+func (b *B2) CopyFile(sourceFileID string, fileName string, opts *CopyOptions) (*FileInfo, error) {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.checkNamePrefix(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.DryRun {
+		b.plan("b2_copy_file", map[string]string{"sourceFileId": sourceFileID, "fileName": fileName, "destinationBucketId": opts.DestinationBucketID})
+		return &FileInfo{AccountID: b.AccountID, Name: fileName, BucketID: opts.DestinationBucketID, conn: b.base()}, nil
+	}
+
+	data, err := json.Marshal(struct {
+		SourceFileID        string            `json:"sourceFileId"`
+		FileName            string            `json:"fileName"`
+		DestinationBucketID string            `json:"destinationBucketId,omitempty"`
+		Range               string            `json:"range,omitempty"`
+		MetadataDirective   string            `json:"metadataDirective,omitempty"`
+		ContentType         string            `json:"contentType,omitempty"`
+		FileInfo            map[string]string `json:"fileInfo,omitempty"`
+	}{
+		SourceFileID:        sourceFileID,
+		FileName:            fileName,
+		DestinationBucketID: opts.DestinationBucketID,
+		Range:               opts.Range,
+		MetadataDirective:   opts.MetadataDirective,
+		ContentType:         opts.ContentType,
+		FileInfo:            opts.Info,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", b.APIUrl+APIsuffix+"/b2_copy_file", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", b.AuthToken)
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo := &FileInfo{conn: b.base()}
+	err = b.readResp(resp, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo, nil
+}
+
+// CopyFile copies an existing file server side to newFileName, into this bucket unless opts.DestinationBucketID is set
+func (b *Bucket) CopyFile(sourceFileID string, newFileName string, opts *CopyOptions) (*FileInfo, error) {
+	withBucket := CopyOptions{}
+	if opts != nil {
+		withBucket = *opts
+	}
+
+	if withBucket.DestinationBucketID == "" {
+		withBucket.DestinationBucketID = b.ID
+	}
+
+	return b.conn.CopyFile(sourceFileID, newFileName, &withBucket)
+}
+
+// UpdateFileMetadata replaces the file info and content type of fileName by copying its latest version onto itself server side.
+// B2 cannot edit metadata in place, so this makes a new version with the same content and leaves the old version stored.
+// An empty contentType keeps the current one
+func (b *Bucket) UpdateFileMetadata(fileName string, newInfo map[string]string, contentType string) (*FileInfo, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	latest, err := conn.latestFileName(b.ID, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType == "" {
+		current, err := conn.GetFileInfo(latest.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		contentType = current.Type
+	}
+
+	return conn.CopyFile(latest.ID, fileName, &CopyOptions{
+		DestinationBucketID: b.ID,
+		MetadataDirective:   MetadataDirectiveReplace,
+		ContentType:         contentType,
+		Info:                newInfo,
+	})
+}
+
+// Rename copies the latest version of oldName to newName server side, then deletes that version of oldName.
+// The source is left untouched if the copy fails. B2 has no native rename, so other versions of oldName remain
+func (b *Bucket) Rename(oldName string, newName string) (*FileInfo, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	latest, err := conn.latestFileName(b.ID, oldName)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := conn.CopyFile(latest.ID, newName, &CopyOptions{DestinationBucketID: b.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.DeleteFileVersion(oldName, latest.ID)
+	if err != nil {
+		return fileInfo, err
+	}
+
+	return fileInfo, nil
+}
+
+// CopyAcross copies a file of this account into a bucket of the dst account, which server side copies cannot do.
+// The download is streamed straight into the upload through a pipe with the SHA1 computed on the fly, so memory stays bounded.
+// Files above B2's single upload size limit cannot be copied this way
+func (b *B2) CopyAcross(dst *B2, dstBucketID string, dstName string, srcFileID string) (*FileInfo, error) {
+	src, cancelSrc := b.operation()
+	defer cancelSrc()
+
+	dstConn, cancelDst := dst.operation()
+	defer cancelDst()
+
+	srcInfo, err := src.GetFileInfo(srcFileID)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	downloadErr := make(chan error, 1)
+	go func() {
+		_, err := src.DownloadFileByID(srcFileID, writer)
+		writer.CloseWithError(err)
+		downloadErr <- err
+	}()
+
+	fileInfo, err := dstConn.uploadPooled(dstBucketID, newSha1AtEndReader(reader), dstName, srcInfo.Length+sha1HexLength, srcInfo.Type, Sha1AtEnd, nil, srcInfo.Info, nil)
+
+	// unblock the download if the upload stopped reading early
+	reader.CloseWithError(io.ErrClosedPipe)
+	dlErr := <-downloadErr
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dlErr != nil {
+		return nil, dlErr
+	}
+
+	// B2 marks SHA1s sent after the content as unverified, on either side, and large files may have none
+	if sha1 := srcInfo.ContentSha1(); sha1 != "" && fileInfo.ContentSha1() != sha1 {
+		return fileInfo, ErrSha1Mismatch
+	}
+
+	return fileInfo, nil
+}
+
+// TransformFile downloads the file srcFileID, passes its content through transform and uploads what transform returns as dstName.
+// The download is piped into the upload with UploadStream, so only one part is held in memory whatever the size of either file,
+// and the SHA1 of each part is computed as it is read. A failed download is returned rather than the upload error it causes.
+// The download holds a request slot until it is read to the end, so a MaxConcurrentRequests of 1 fails with ErrTooFewRequests
+func (b *Bucket) TransformFile(srcFileID string, dstName string, transform func(io.Reader) io.Reader, contentType string, info map[string]string) (*FileInfo, error) {
+	// the upload would wait forever for the slot of the download it reads from
+	if b.conn.MaxConcurrentRequests == 1 {
+		return nil, ErrTooFewRequests
+	}
+
+	src, cancel := b.conn.operation()
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	downloadErr := make(chan error, 1)
+	go func() {
+		_, err := src.DownloadFileByID(srcFileID, writer)
+		writer.CloseWithError(err)
+		downloadErr <- err
+	}()
+
+	fileInfo, err := b.UploadStream(transform(reader), dstName, 0, contentType, info)
+
+	// unblock the download if the upload failed or transform stopped reading early
+	reader.CloseWithError(io.ErrClosedPipe)
+	dlErr := <-downloadErr
+
+	if dlErr != nil && !errors.Is(dlErr, io.ErrClosedPipe) {
+		return nil, dlErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo, nil
+}
@@ -0,0 +1,59 @@
+package b2
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// decoder a reader decompressing a download body, and the raw body it reads from
+type decoder struct {
+	io.ReadCloser
+	raw *hashingReader
+}
+
+// decompressor wraps the body of a whole file download in a decompressing reader when DownloadDecompress is set and its
+// Content-Encoding is gzip or deflate. The raw body is hashed, since the SHA1 of B2 is the one of the stored compressed bytes.
+// It returns nil when the body is to be copied as is
+func (b *B2) decompressor(resp *http.Response) (*decoder, error) {
+	if !b.DownloadDecompress || resp.StatusCode != GoodStatus {
+		return nil, nil
+	}
+
+	raw := newHashingReader(resp.Body, false)
+
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		reader, err = gzip.NewReader(raw)
+	case "deflate":
+		reader, err = zlib.NewReader(raw)
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &decoder{ReadCloser: reader, raw: raw}, nil
+}
+
+// verify reads what is left of the raw body and compares its SHA1 with the one of B2, if it has one
+func (d *decoder) verify(sha1 string) error {
+	_, err := io.Copy(ioutil.Discard, d.raw)
+	if err != nil {
+		return err
+	}
+
+	sha1 = strings.TrimPrefix(sha1, "unverified:")
+	if isSha1Hex(sha1) && d.raw.Sha1() != sha1 {
+		return ErrSha1Mismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package b2
+
+// PlannedAction mutating call recorded instead of being sent while B2.DryRun is set
+type PlannedAction struct {
+	Endpoint string
+	Params   map[string]string
+}
+
+// plan records a mutating call that DryRun kept from being sent
+func (b *B2) plan(endpoint string, params map[string]string) {
+	state := b.state()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.planned = append(state.planned, PlannedAction{Endpoint: endpoint, Params: params})
+}
+
+// PlannedActions returns the mutating calls recorded while DryRun was set, in the order they were made
+func (b *B2) PlannedActions() []PlannedAction {
+	state := b.state()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	return append([]PlannedAction(nil), state.planned...)
+}
@@ -0,0 +1,54 @@
+package b2
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidCustomerKey an SSE-C key is not the 32 bytes of an AES256 key
+var ErrInvalidCustomerKey = errors.New("SSE-C key must be 32 bytes")
+
+// CustomerKeyAlgorithm the only algorithm B2 supports for SSE-C keys
+const CustomerKeyAlgorithm = "AES256"
+
+// CustomerKey an AES256 key of a file encrypted by B2 with a key the customer provides (SSE-C).
+// B2 does not store the key, every download of such a file has to send it again
+type CustomerKey struct {
+	Key []byte
+}
+
+// header adds the SSE-C headers of the key to req
+func (k *CustomerKey) header(req *http.Request) error {
+	if len(k.Key) != 32 {
+		return ErrInvalidCustomerKey
+	}
+
+	digest := md5.Sum(k.Key)
+
+	req.Header.Set("X-Bz-Server-Side-Encryption-Customer-Algorithm", CustomerKeyAlgorithm)
+	req.Header.Set("X-Bz-Server-Side-Encryption-Customer-Key", base64.StdEncoding.EncodeToString(k.Key))
+	req.Header.Set("X-Bz-Server-Side-Encryption-Customer-Key-Md5", base64.StdEncoding.EncodeToString(digest[:]))
+
+	return nil
+}
+
+// WithEncryption returns a shallow copy of b whose downloads, ranged, concurrent, by ID or by name alike, and HEAD requests
+// send key. Buckets and files returned through the copy refer back to the original B2, without the key
+func (b *B2) WithEncryption(key *CustomerKey) *B2 {
+	b2 := *b
+	b2.Encryption = key
+	b2.root = b.base()
+
+	return &b2
+}
+
+// encrypt adds the SSE-C headers of Encryption to a download request, if it is set
+func (b *B2) encrypt(req *http.Request) error {
+	if b.Encryption == nil {
+		return nil
+	}
+
+	return b.Encryption.header(req)
+}
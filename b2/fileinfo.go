@@ -2,9 +2,24 @@ package b2
 
 import (
 	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// FileInfo B2 file information
+// file info keys B2 serves back as the matching response header when the file is downloaded
+const (
+	InfoKeyCacheControl       = "b2-cache-control"
+	InfoKeyContentDisposition = "b2-content-disposition"
+	InfoKeyContentEncoding    = "b2-content-encoding"
+	InfoKeyContentLanguage    = "b2-content-language"
+	InfoKeyExpires            = "b2-expires"
+)
+
+// SrcLastModifiedInfoKey file info key uploads store the source modification time under, in UNIX milliseconds
+const SrcLastModifiedInfoKey = "src_last_modified_millis"
+
+// FileInfo B2 file information, URL is only set by downloads and holds where the file was finally downloaded from after any redirect, with a download authorization token in it redacted
 type FileInfo struct {
 	AccountID string            `json:"accountId"`
 	ID        string            `json:"fileId"`
@@ -14,7 +29,42 @@ type FileInfo struct {
 	Sha1      string            `json:"contentSha1"`
 	Type      string            `json:"contentType"`
 	Info      map[string]string `json:"fileInfo"`
-	conn      *B2
+	URL       string            `json:"-"`
+
+	// Decompressed is set by downloads decompressed with DownloadDecompress, DecompressedLength is then the amount of bytes written
+	Decompressed       bool  `json:"-"`
+	DecompressedLength int64 `json:"-"`
+
+	ContentMd5           string                `json:"contentMd5"`
+	ServerSideEncryption *ServerSideEncryption `json:"serverSideEncryption"`
+	FileRetention        *FileRetention        `json:"fileRetention"`
+	LegalHold            *LegalHold            `json:"legalHold"`
+
+	conn *B2
+}
+
+// ServerSideEncryption B2 encryption of a file, Mode is "SSE-B2" or "SSE-C" when encrypted
+type ServerSideEncryption struct {
+	Mode      string `json:"mode"`
+	Algorithm string `json:"algorithm"`
+}
+
+// FileRetention B2 object lock retention of a file, Value is nil when the client may not read it or none is set
+type FileRetention struct {
+	IsClientAuthorizedToRead bool            `json:"isClientAuthorizedToRead"`
+	Value                    *RetentionValue `json:"value"`
+}
+
+// RetentionValue B2 retention mode ("governance" or "compliance") and the UNIX milliseconds it lasts until
+type RetentionValue struct {
+	Mode                 string `json:"mode"`
+	RetainUntilTimestamp int64  `json:"retainUntilTimestamp"`
+}
+
+// LegalHold B2 legal hold of a file, Value is "on" or "off" when the client may read it
+type LegalHold struct {
+	IsClientAuthorizedToRead bool   `json:"isClientAuthorizedToRead"`
+	Value                    string `json:"value"`
 }
 
 // Download downloads this file ID's content
@@ -22,12 +72,49 @@ func (f *FileInfo) Download(output io.Writer) (*FileInfo, error) {
 	return f.conn.DownloadFileByID(f.ID, output)
 }
 
+// DownloadRange downloads the bytes from start to end, inclusive, of this file ID's content
+func (f *FileInfo) DownloadRange(start int64, end int64, output io.Writer) (*FileInfo, error) {
+	return f.conn.DownloadFileRangeByID(f.ID, start, end, output)
+}
+
 // Delete deletes this version of the file
 func (f *FileInfo) Delete() (*FileInfo, error) {
 	return f.conn.DeleteFileVersion(f.Name, f.ID)
 }
 
+// DeleteBypass deletes this version of the file even under a governance mode retention, see B2.DeleteFileVersionBypass
+func (f *FileInfo) DeleteBypass() (*FileInfo, error) {
+	return f.conn.DeleteFileVersionBypass(f.Name, f.ID, true)
+}
+
 // Hide hides a file so that downloading by name will not find the file, but previous versions of the file are still stored. See File Versions about what it means to hide a file
 func (f *FileInfo) Hide() (*FileName, error) {
 	return f.conn.HideFile(f.BucketID, f.Name)
 }
+
+// ContentSha1 the hex SHA1 of the whole file, without the "unverified:" mark of SHA1s sent after the content.
+// For large files, whose Sha1 is Sha1None, it is the LargeFileSha1InfoKey info if they were started with one, else empty
+func (f *FileInfo) ContentSha1() string {
+	sha1 := strings.TrimPrefix(f.Sha1, "unverified:")
+	if sha1 == Sha1None {
+		sha1 = f.Info[LargeFileSha1InfoKey]
+	}
+
+	if sha1 == Sha1None {
+		return ""
+	}
+
+	return sha1
+}
+
+// SrcLastModified the source modification time stored under SrcLastModifiedInfoKey by uploads given an mtime, if any.
+// The value is the same whether the file info came from a listing or from the headers of a download
+func (f *FileInfo) SrcLastModified() (time.Time, bool) {
+	millis, err := strconv.ParseInt(f.Info[SrcLastModifiedInfoKey], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// split rather than multiply, so that no value can overflow the nanoseconds
+	return time.Unix(millis/1000, millis%1000*int64(time.Millisecond)), true
+}
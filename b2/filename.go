@@ -1,6 +1,26 @@
 package b2
 
-// FileName B2 file name
+import (
+	"io"
+	"time"
+)
+
+// file version actions, as listed in FileName.Action
+const (
+	// ActionUpload a version holding uploaded content
+	ActionUpload = "upload"
+
+	// ActionHide a hide marker, making the name invisible to downloads by name without deleting earlier versions
+	ActionHide = "hide"
+
+	// ActionStart a large file that was started but not finished yet
+	ActionStart = "start"
+
+	// ActionFolder a virtual folder listed when a delimiter is used
+	ActionFolder = "folder"
+)
+
+// FileName B2 file name, or a hide marker when its Action is ActionHide: its ID is then the ID of the marker itself
 type FileName struct {
 	ID        string `json:"fileId"`
 	Name      string `json:"fileName"`
@@ -14,3 +34,22 @@ type FileName struct {
 func (f *FileName) GetFileInfo() (*FileInfo, error) {
 	return f.conn.GetFileInfo(f.ID)
 }
+
+// Download downloads the content of this exact version of the file, hide markers have none and return ErrNotFound
+func (f *FileName) Download(output io.Writer) (*FileInfo, error) {
+	if f.IsHideMarker() {
+		return nil, ErrNotFound
+	}
+
+	return f.conn.DownloadFileByID(f.ID, output)
+}
+
+// IsHideMarker tells whether this version is a hide marker rather than uploaded content
+func (f *FileName) IsHideMarker() bool {
+	return f.Action == ActionHide
+}
+
+// Time the time this version was uploaded, or hidden for a hide marker, from its Timestamp in milliseconds
+func (f *FileName) Time() time.Time {
+	return time.Unix(0, f.Timestamp*int64(time.Millisecond))
+}
@@ -0,0 +1,31 @@
+package b2
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHideFileResponse(t *testing.T) {
+	conn := newTestB2(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"action":"hide","fileId":"4_hide_marker","fileName":"photo.jpg","size":0,"uploadTimestamp":1437815673123}`))
+	})
+
+	hidden, err := conn.HideFile("bucket", "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hidden.ID != "4_hide_marker" || hidden.Name != "photo.jpg" || hidden.Action != ActionHide {
+		t.Errorf("unexpected hide marker %+v", hidden)
+	}
+
+	if !hidden.IsHideMarker() {
+		t.Error("expected a hide marker")
+	}
+
+	expected := time.Date(2015, time.July, 25, 9, 14, 33, 123*int(time.Millisecond), time.UTC)
+	if !hidden.Time().Equal(expected) {
+		t.Errorf("expected the marker to be from %v, got %v", expected, hidden.Time().UTC())
+	}
+}
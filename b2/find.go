@@ -0,0 +1,128 @@
+package b2
+
+import (
+	"path"
+	"regexp"
+	"time"
+)
+
+// FindOptions criteria of the files FindFiles returns, unset criteria match every file
+type FindOptions struct {
+	// Prefix only lists the files whose name starts with it
+	Prefix string
+
+	// NamePattern glob matched against the base name of the files, the part after the last '/', as with path.Match
+	NamePattern string
+
+	// NameRegexp matched against the whole name of the files
+	NameRegexp *regexp.Regexp
+
+	// MinAge only matches files uploaded at least this long ago
+	MinAge time.Duration
+
+	// MaxAge only matches files uploaded at most this long ago
+	MaxAge time.Duration
+}
+
+// match tells whether file meets the criteria, with ages relative to now
+func (o *FindOptions) match(file FileName, now time.Time) (bool, error) {
+	if o.NamePattern != "" {
+		matched, err := path.Match(o.NamePattern, path.Base(file.Name))
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	if o.NameRegexp != nil && !o.NameRegexp.MatchString(file.Name) {
+		return false, nil
+	}
+
+	age := now.Sub(file.Time())
+	if o.MinAge > 0 && age < o.MinAge {
+		return false, nil
+	}
+
+	if o.MaxAge > 0 && age > o.MaxAge {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// FindFilesFunc calls fn with every visible file matching opts, one page of listing in memory at a time.
+// Walking stops at the first error returned by fn, which is returned
+func (b *Bucket) FindFilesFunc(opts *FindOptions, fn func(FileName) error) error {
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+
+	if opts.NamePattern != "" {
+		// reject a malformed pattern up front rather than after listing
+		_, err := path.Match(opts.NamePattern, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	now := time.Now()
+	listOpts := &ListOptions{
+		Prefix:       opts.Prefix,
+		MaxFileCount: listPageSize,
+	}
+
+	for {
+		files, nextFileName, err := conn.ListFileNamesWithOptions(b.ID, listOpts)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			matched, err := opts.match(file, now)
+			if err != nil {
+				return err
+			}
+
+			if !matched {
+				continue
+			}
+
+			err = fn(file)
+			if err != nil {
+				return err
+			}
+		}
+
+		if nextFileName == "" {
+			return nil
+		}
+
+		listOpts.StartFileName = nextFileName
+	}
+}
+
+// FindFiles lists every visible file matching opts, following every page.
+// ErrTooManyResults is returned rather than accumulating more than MaxListResults matches, see FindFilesFunc to avoid the limit
+func (b *Bucket) FindFiles(opts FindOptions) ([]FileName, error) {
+	maxResults := b.conn.MaxListResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxListResults
+	}
+
+	var found []FileName
+	err := b.FindFilesFunc(&opts, func(file FileName) error {
+		if len(found) >= maxResults {
+			return ErrTooManyResults
+		}
+
+		found = append(found, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
@@ -0,0 +1,120 @@
+package b2
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+)
+
+// Md5InfoKey file info key the hex MD5 of the content is stored under when PutOptions.ComputeMd5 is set.
+// B2 does not verify it, it only records the MD5 for other systems
+const Md5InfoKey = "md5"
+
+// hashingReader passes the data of r through while computing its SHA1, and its MD5 if asked to, in the same pass
+type hashingReader struct {
+	r    io.Reader
+	sha1 hash.Hash
+	md5  hash.Hash
+	size int64
+}
+
+// newHashingReader wraps r to hash what is read from it, withMd5 also computes the MD5
+func newHashingReader(r io.Reader, withMd5 bool) *hashingReader {
+	reader := &hashingReader{r: r, sha1: sha1.New()}
+	if withMd5 {
+		reader.md5 = md5.New()
+	}
+
+	return reader
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.sha1.Write(p[:n])
+		if r.md5 != nil {
+			r.md5.Write(p[:n])
+		}
+
+		r.size += int64(n)
+	}
+
+	return n, err
+}
+
+// Sha1 the hex SHA1 of everything read so far
+func (r *hashingReader) Sha1() string {
+	return hex.EncodeToString(r.sha1.Sum(nil))
+}
+
+// Md5 the hex MD5 of everything read so far, empty when it is not computed
+func (r *hashingReader) Md5() string {
+	if r.md5 == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(r.md5.Sum(nil))
+}
+
+// Sha1HexDigest encodes a raw SHA1 digest as the hex string uploads take, ErrInvalidSha1 is returned unless it is 20 bytes
+func Sha1HexDigest(digest []byte) (string, error) {
+	if len(digest) != sha1.Size {
+		return "", ErrInvalidSha1
+	}
+
+	return hex.EncodeToString(digest), nil
+}
+
+// Sha1Hex reads r to its end and returns its lowercase hex SHA1, as uploads take it, with the amount of bytes read.
+// A seekable r must be seeked back before uploading it, see Sha1HexDigest to encode a digest already computed
+func Sha1Hex(r io.Reader) (string, int64, error) {
+	reader := newHashingReader(r, false)
+	_, err := copyPooled(ioutil.Discard, reader, DefaultCopyBufferSize)
+	if err != nil {
+		return "", reader.size, err
+	}
+
+	return reader.Sha1(), reader.size, nil
+}
+
+// hashSums computes the hex SHA1, the hex MD5 if withMd5 is set, and the size of the rest of r in one pass,
+// then seeks r back to where it was
+func hashSums(r io.ReadSeeker, withMd5 bool) (string, string, int64, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	reader := newHashingReader(r, withMd5)
+	_, err = copyPooled(ioutil.Discard, reader, DefaultCopyBufferSize)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	_, err = r.Seek(start, io.SeekStart)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return reader.Sha1(), reader.Md5(), reader.size, nil
+}
+
+// sha1Sum computes the hex SHA1 and size of the rest of r, then seeks r back to where it was
+func sha1Sum(r io.ReadSeeker) (string, int64, error) {
+	sha1, _, size, err := hashSums(r, false)
+	return sha1, size, err
+}
+
+// withInfo returns a copy of info with key set to value, info itself is left untouched
+func withInfo(info map[string]string, key string, value string) map[string]string {
+	merged := make(map[string]string, len(info)+1)
+	for k, v := range info {
+		merged[k] = v
+	}
+
+	merged[key] = value
+	return merged
+}
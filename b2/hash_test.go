@@ -0,0 +1,44 @@
+package b2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSha1HexDigestLeadingZeros(t *testing.T) {
+	digest := []byte{0, 0, 0x0f, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17}
+
+	sha1, err := Sha1HexDigest(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sha1 != "00000f0102030405060708090a0b0c0d0e0f1011" {
+		t.Errorf("unexpected hex %q", sha1)
+	}
+
+	_, err = Sha1HexDigest(digest[1:])
+	if !errors.Is(err, ErrInvalidSha1) {
+		t.Errorf("expected ErrInvalidSha1 for a short digest, got %v", err)
+	}
+}
+
+func TestSha1Hex(t *testing.T) {
+	vectors := map[string]string{
+		"":    EmptySha1,
+		"abc": "a9993e364706816aba3e25717850c26c9cd0d89d",
+		"The quick brown fox jumps over the lazy dog": "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12",
+	}
+
+	for content, expected := range vectors {
+		sha1, size, err := Sha1Hex(strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sha1 != expected || size != int64(len(content)) {
+			t.Errorf("%q: expected %s of %d bytes, got %s of %d bytes", content, expected, len(content), sha1, size)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package b2
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONContentType the content type of files uploaded with UploadJSON
+const JSONContentType = "application/json"
+
+// UploadJSON uploads v marshalled to JSON as fileName, with JSONContentType
+func (b *Bucket) UploadJSON(fileName string, v interface{}, info map[string]string) (*FileInfo, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Put(fileName, bytes.NewReader(data), &PutOptions{ContentType: JSONContentType, Info: info})
+}
+
+// DownloadJSON downloads the latest version of fileName and unmarshals it into v.
+// The file is read into memory, so it fails with ErrDownloadTooLarge above B2.MaxDownloadBytes
+func (b *Bucket) DownloadJSON(fileName string, v interface{}) error {
+	data, _, err := b.conn.DownloadBytesByName(b.Name, fileName)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,713 @@
+package b2
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sha1None content SHA1 B2 reports for large files, which only have a SHA1 per part.
+// The SHA1 of the whole file can only be kept in the file info as LargeFileSha1InfoKey
+const Sha1None = "none"
+
+// LargeFileSha1InfoKey file info key used by B2 tooling to store the SHA1 of a whole large file.
+// B2 treats it as informational only and never verifies it
+const LargeFileSha1InfoKey = "large_file_sha1"
+
+// MaxParts the most parts a large file may have
+const MaxParts = 10000
+
+// MaxPartSize the largest part B2 accepts
+const MaxPartSize = 5 * 1000 * 1000 * 1000
+
+// DefaultPartSize the part size used when B2 did not recommend one
+const DefaultPartSize = 100 * 1000 * 1000
+
+// ErrMissingPart a large file was finished while a part before the last one had not been uploaded
+var ErrMissingPart = errors.New("Large file is missing a part")
+
+// ErrNoParts a large file was finished without any part
+var ErrNoParts = errors.New("Large file has no parts")
+
+// ErrInvalidSha1 a SHA1 is not 40 hex digits
+var ErrInvalidSha1 = errors.New("Invalid SHA1")
+
+// ErrInvalidPartSize a part smaller than the absolute minimum part size, or of another size than the other parts, is not the last part
+var ErrInvalidPartSize = errors.New("Only the last part of a large file may be smaller than the others or than the minimum part size")
+
+// LargeFile B2 large file being uploaded in parts
+type LargeFile struct {
+	AccountID string            `json:"accountId"`
+	ID        string            `json:"fileId"`
+	Name      string            `json:"fileName"`
+	BucketID  string            `json:"bucketId"`
+	Type      string            `json:"contentType"`
+	Info      map[string]string `json:"fileInfo"`
+	Timestamp int64             `json:"uploadTimestamp"`
+	conn      *B2
+	mu        sync.Mutex
+	partSha1s []string
+	partSizes map[int]int64
+	urls      []*UploadPartURL
+}
+
+// UploadPartURL B2 URL for uploading the parts of one large file, it must only be used by one upload at a time
+type UploadPartURL struct {
+	FileID    string `json:"fileId"`
+	UploadURL string `json:"uploadUrl"`
+	AuthToken string `json:"authorizationToken"`
+	conn      *B2
+}
+
+// String describes the upload part URL with its authorization token redacted
+func (u UploadPartURL) String() string {
+	return fmt.Sprintf("{FileID:%s UploadURL:%s AuthToken:%s}", u.FileID, u.UploadURL, redact(u.AuthToken))
+}
+
+// GoString describes the upload part URL with its authorization token redacted for the %#v verb
+func (u UploadPartURL) GoString() string {
+	return fmt.Sprintf("b2.UploadPartURL{FileID:%q, UploadURL:%q, AuthToken:%q}", u.FileID, u.UploadURL, redact(u.AuthToken))
+}
+
+// Part B2 part of a large file
+type Part struct {
+	FileID    string `json:"fileId"`
+	Number    int    `json:"partNumber"`
+	Length    int64  `json:"contentLength"`
+	Sha1      string `json:"contentSha1"`
+	Timestamp int64  `json:"uploadTimestamp"`
+}
+
+// StartLargeFile prepares for uploading the parts of a large file.
+// B2 only accepts file info when a large file is started, so LargeFileSha1InfoKey must be set here if wanted
+func (b *B2) StartLargeFile(bucketID string, fileName string, contentType string, info map[string]string) (*LargeFile, error) {
+	err := b.checkNamePrefix(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.DryRun {
+		b.plan("b2_start_large_file", map[string]string{"bucketId": bucketID, "fileName": fileName})
+		return &LargeFile{AccountID: b.AccountID, Name: fileName, BucketID: bucketID, Type: contentType, Info: info, conn: b.base()}, nil
+	}
+
+	// use B2's autodetect content type if one is not passed
+	if contentType == "" {
+		contentType = "b2/x-auto"
+	}
+
+	data, err := json.Marshal(struct {
+		BucketID    string            `json:"bucketId"`
+		FileName    string            `json:"fileName"`
+		ContentType string            `json:"contentType"`
+		FileInfo    map[string]string `json:"fileInfo,omitempty"`
+	}{
+		BucketID:    bucketID,
+		FileName:    fileName,
+		ContentType: contentType,
+		FileInfo:    info,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", b.APIUrl+APIsuffix+"/b2_start_large_file", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", b.AuthToken)
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	largeFile := &LargeFile{conn: b.base()}
+	err = b.readResp(resp, largeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return largeFile, nil
+}
+
+// GetUploadPartURL gets an URL to use for uploading the parts of a large file
+func (b *B2) GetUploadPartURL(fileID string) (*UploadPartURL, error) {
+	// there is no large file to get an URL for, parts are planned instead
+	if b.DryRun {
+		return &UploadPartURL{FileID: fileID, conn: b.base()}, nil
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"fileId": fileID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", b.APIUrl+APIsuffix+"/b2_get_upload_part_url", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", b.AuthToken)
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &UploadPartURL{conn: b.base()}
+	err = b.readResp(resp, upload)
+	if err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// FinishLargeFile converts the parts that have been uploaded into a single B2 file.
+// partSha1s holds the hex SHA1 of every part in part number order, part 1 at index 0, so parts uploaded elsewhere can be finished here
+func (b *B2) FinishLargeFile(fileID string, partSha1s []string) (*FileInfo, error) {
+	err := validatePartSha1s(partSha1s)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.DryRun {
+		b.plan("b2_finish_large_file", map[string]string{"fileId": fileID})
+		return &FileInfo{AccountID: b.AccountID, ID: fileID, conn: b.base()}, nil
+	}
+
+	data, err := json.Marshal(struct {
+		FileID        string   `json:"fileId"`
+		PartSha1Array []string `json:"partSha1Array"`
+	}{
+		FileID:        fileID,
+		PartSha1Array: partSha1s,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", b.APIUrl+APIsuffix+"/b2_finish_large_file", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", b.AuthToken)
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo := &FileInfo{conn: b.base()}
+	err = b.readResp(resp, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo, nil
+}
+
+// CancelLargeFile cancels the upload of a large file and deletes the parts that have been uploaded
+func (b *B2) CancelLargeFile(fileID string) (*LargeFile, error) {
+	if b.DryRun {
+		b.plan("b2_cancel_large_file", map[string]string{"fileId": fileID})
+		return &LargeFile{AccountID: b.AccountID, ID: fileID, conn: b.base()}, nil
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"fileId": fileID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", b.APIUrl+APIsuffix+"/b2_cancel_large_file", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", b.AuthToken)
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	largeFile := &LargeFile{conn: b.base()}
+	err = b.readResp(resp, largeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return largeFile, nil
+}
+
+// MaxUnfinishedLargeFileCount the most large files B2 returns from one listing of unfinished large files
+const MaxUnfinishedLargeFileCount = 100
+
+// ListUnfinishedLargeFiles lists the large files of a bucket that were started but neither finished nor cancelled, from startFileID.
+// maxFileCount is clamped to MaxUnfinishedLargeFileCount, the returned ID is the start of the next page or empty on the last one
+func (b *B2) ListUnfinishedLargeFiles(bucketID string, startFileID string, maxFileCount int) ([]*LargeFile, string, error) {
+	maxFileCount, err := b.clampMaxFileCount(maxFileCount)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if maxFileCount > MaxUnfinishedLargeFileCount {
+		maxFileCount = MaxUnfinishedLargeFileCount
+	}
+
+	data, err := json.Marshal(struct {
+		BucketID     string `json:"bucketId"`
+		StartFileID  string `json:"startFileId,omitempty"`
+		MaxFileCount int    `json:"maxFileCount,omitempty"`
+	}{
+		BucketID:     bucketID,
+		StartFileID:  startFileID,
+		MaxFileCount: maxFileCount,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequest("POST", b.APIUrl+APIsuffix+"/b2_list_unfinished_large_files", bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.Header.Add("Authorization", b.AuthToken)
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	files := &struct {
+		Files      []*LargeFile `json:"files"`
+		NextFileID string       `json:"nextFileId"`
+	}{}
+	err = b.readResp(resp, files)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, file := range files.Files {
+		file.conn = b.base()
+	}
+
+	return files.Files, files.NextFileID, nil
+}
+
+// PartSizeFor returns the part size to upload a large file of totalSize bytes with.
+// It is the recommended part size, at least the absolute minimum part size, raised as needed to stay within MaxParts
+func (b *B2) PartSizeFor(totalSize int64) int64 {
+	partSize := b.RecommendedPartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+
+	if partSize < b.AbsoluteMinimumPartSize {
+		partSize = b.AbsoluteMinimumPartSize
+	}
+
+	if minimum := (totalSize + MaxParts - 1) / MaxParts; partSize < minimum {
+		partSize = minimum
+	}
+
+	if partSize > MaxPartSize {
+		partSize = MaxPartSize
+	}
+
+	return partSize
+}
+
+// UploadPart uploads one part of a large file, part numbers start at 1
+func (u *UploadPartURL) UploadPart(partNumber int, data io.Reader, size int64, sha1 string) (*Part, error) {
+	// every part needs its own SHA1, Sha1None is only what B2 reports for the whole file
+	if sha1 != Sha1AtEnd && !isSha1Hex(sha1) {
+		return nil, ErrInvalidSha1
+	}
+
+	if u.conn != nil && u.conn.DryRun {
+		u.conn.plan("b2_upload_part", map[string]string{"fileId": u.FileID, "partNumber": strconv.Itoa(partNumber), "contentSha1": sha1})
+		return &Part{FileID: u.FileID, Number: partNumber, Length: size, Sha1: sha1}, nil
+	}
+
+	req, err := http.NewRequest("POST", u.UploadURL, u.conn.limitReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = size
+
+	req.Header.Add("Authorization", u.AuthToken)
+	req.Header.Add("X-Bz-Part-Number", strconv.Itoa(partNumber))
+	req.Header.Add("X-Bz-Content-Sha1", sha1)
+	if u.conn != nil && u.conn.ExpectContinue {
+		req.Header.Set("Expect", "100-continue")
+	}
+
+	resp, err := u.conn.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	part := &Part{}
+	err = u.conn.readResp(resp, part)
+	if err != nil {
+		return nil, err
+	}
+
+	return part, nil
+}
+
+// UploadPart uploads one part of this large file, part numbers start at 1.
+// Parts may be uploaded concurrently, each upload uses its own upload part URL.
+// When data is an io.ReadSeeker, a failed part is retried up to MaxRetries times with Backoff, each time with a new upload part URL
+func (l *LargeFile) UploadPart(partNumber int, data io.Reader, size int64, sha1 string) (*Part, error) {
+	err := l.reservePart(partNumber, size)
+	if err != nil {
+		return nil, err
+	}
+
+	part, upload, err := l.uploadPart(partNumber, data, size, sha1)
+	if err != nil {
+		l.releasePart(partNumber)
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.urls = append(l.urls, upload)
+	for len(l.partSha1s) < partNumber {
+		l.partSha1s = append(l.partSha1s, "")
+	}
+	l.partSha1s[partNumber-1] = part.Sha1
+
+	return part, nil
+}
+
+// uploadPart uploads one part with an idle or new upload part URL, retrying it with a new URL as UploadPart tells.
+// It returns the URL the part was uploaded with, failed URLs are dropped since they may be the reason of the failure
+func (l *LargeFile) uploadPart(partNumber int, data io.Reader, size int64, sha1 string) (*Part, *UploadPartURL, error) {
+	rewind := func() error { return ErrNotSeekable }
+	if seeker, ok := data.(io.ReadSeeker); ok {
+		if _, replay, err := l.conn.replayable(seeker, size); err == nil {
+			rewind = replay
+		}
+	}
+
+	for retry := 1; ; retry++ {
+		upload, err := l.acquireURL()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		part, err := upload.UploadPart(partNumber, data, size, sha1)
+		if err == nil || !retryable(err) {
+			return part, upload, err
+		}
+
+		if podUnavailable(err) {
+			l.discardURLHost(upload)
+		}
+
+		// an expired token is retried once even without MaxRetries, the new URL comes with a new token
+		if retry > l.conn.MaxRetries && !(retry == 1 && errors.Is(err, ErrExpiredAuthToken)) {
+			return nil, nil, err
+		}
+
+		if rewindErr := rewind(); rewindErr != nil {
+			return nil, nil, err
+		}
+
+		if sleepErr := l.conn.sleep(l.conn.retryDelay(err, retry)); sleepErr != nil {
+			return nil, nil, err
+		}
+
+		l.conn.observeRetry("b2_upload_part")
+	}
+}
+
+// reservePart records the size of a part before it is uploaded, failing with ErrInvalidPartSize if B2 would refuse to finish
+// the file with it: every part but the last must have the same size, at least the absolute minimum part size
+func (l *LargeFile) reservePart(partNumber int, size int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.partSizes == nil {
+		l.partSizes = map[int]int64{}
+	}
+
+	last := partNumber
+	for number := range l.partSizes {
+		if number > last {
+			last = number
+		}
+	}
+
+	var minimum int64
+	if l.conn != nil {
+		minimum = l.conn.AbsoluteMinimumPartSize
+	}
+
+	common := int64(-1)
+	check := func(number int, partSize int64) bool {
+		if number == last {
+			return true
+		}
+
+		if partSize < minimum {
+			return false
+		}
+
+		if common < 0 {
+			common = partSize
+		}
+
+		return partSize == common
+	}
+
+	if !check(partNumber, size) {
+		return ErrInvalidPartSize
+	}
+
+	for number, partSize := range l.partSizes {
+		if number != partNumber && !check(number, partSize) {
+			return ErrInvalidPartSize
+		}
+	}
+
+	l.partSizes[partNumber] = size
+	return nil
+}
+
+// releasePart forgets the size of a part whose upload failed
+func (l *LargeFile) releasePart(partNumber int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.partSizes, partNumber)
+}
+
+// acquireURL takes an idle upload part URL or gets a new one
+func (l *LargeFile) acquireURL() (*UploadPartURL, error) {
+	l.mu.Lock()
+	if len(l.urls) > 0 {
+		upload := l.urls[len(l.urls)-1]
+		l.urls = l.urls[:len(l.urls)-1]
+		l.mu.Unlock()
+		return upload, nil
+	}
+	l.mu.Unlock()
+
+	upload, err := l.conn.GetUploadPartURL(l.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// parts are sent within the same context as this large file
+	upload.conn = l.conn
+
+	return upload, nil
+}
+
+// discardURLHost drops the idle upload part URLs pointing to the same host as upload, see B2.discardUploadHost
+func (l *LargeFile) discardURLHost(upload *UploadPartURL) {
+	host := uploadHost(upload.UploadURL)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := l.urls[:0]
+	for _, idle := range l.urls {
+		if uploadHost(idle.UploadURL) != host {
+			kept = append(kept, idle)
+		}
+	}
+	l.urls = kept
+}
+
+// validatePartSha1s checks an ordered list of part SHA1s has no gap and only well formed SHA1s
+func validatePartSha1s(partSha1s []string) error {
+	if len(partSha1s) == 0 {
+		return ErrNoParts
+	}
+
+	for _, partSha1 := range partSha1s {
+		if partSha1 == "" {
+			return ErrMissingPart
+		}
+
+		if !isSha1Hex(partSha1) {
+			return ErrInvalidSha1
+		}
+	}
+
+	return nil
+}
+
+// isSha1Hex whether s is a SHA1 in hex
+func isSha1Hex(s string) bool {
+	if len(s) != sha1HexLength {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Finish converts the parts uploaded with UploadPart into a single B2 file
+func (l *LargeFile) Finish() (*FileInfo, error) {
+	l.mu.Lock()
+	partSha1s := append([]string(nil), l.partSha1s...)
+	l.mu.Unlock()
+
+	return l.conn.FinishLargeFile(l.ID, partSha1s)
+}
+
+// Cancel cancels the upload of this large file and deletes the parts that have been uploaded
+func (l *LargeFile) Cancel() error {
+	_, err := l.conn.CancelLargeFile(l.ID)
+	return err
+}
+
+// cancelLargeFileTimeout bounds the cancellation of a large file whose upload failed
+const cancelLargeFileTimeout = 30 * time.Second
+
+// cancelDetached cancels the large file on a context of its own, the context of the operation uploading it may be what made it fail
+func (l *LargeFile) cancelDetached() error {
+	conn := l.conn.base()
+	ctx, cancel := context.WithTimeout(conn.context(), cancelLargeFileTimeout)
+	defer cancel()
+
+	_, err := conn.WithContext(ctx).CancelLargeFile(l.ID)
+	return err
+}
+
+// abandon cancels the large file after err made its upload fail.
+// It returns err, along with the cancellation error when the large file is left unfinished
+func (l *LargeFile) abandon(err error) error {
+	cancelErr := l.cancelDetached()
+	if cancelErr != nil {
+		return fmt.Errorf("%w (cancelling large file %s: %v)", err, l.ID, cancelErr)
+	}
+
+	return err
+}
+
+// UploadLargeFile uploads data as a large file of fileSize bytes, in parts of partSize bytes (PartSizeFor(fileSize) when 0).
+// sha1 is the optional SHA1 of the whole file, stored as LargeFileSha1InfoKey. B2 only accepts file info when the upload starts,
+// so it cannot be computed while the parts stream and must be supplied by the caller. An empty file fails with ErrNoParts, upload it with UploadFile.
+// A file of at most one part is uploaded with UploadFile instead
+func (b *Bucket) UploadLargeFile(data io.Reader, fileName string, fileSize int64, partSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string) (*FileInfo, error) {
+	// B2 refuses to finish a large file without parts, which would be left unfinished
+	if fileSize <= 0 {
+		return nil, ErrNoParts
+	}
+
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	if partSize <= 0 {
+		partSize = conn.PartSizeFor(fileSize)
+	}
+
+	// B2 refuses to finish a large file of a single part either, so what fits in one part is uploaded as a regular file
+	if fileSize <= partSize {
+		buf := make([]byte, fileSize)
+		_, err := io.ReadFull(data, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		if sha1 == "" || sha1 == Sha1None {
+			sha1, _, err = Sha1Hex(bytes.NewReader(buf))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return b.UploadFile(bytes.NewReader(buf), fileName, fileSize, contentType, sha1, mtime, info)
+	}
+
+	fileInfo := map[string]string{}
+	for name, value := range b.fileInfo(info) {
+		fileInfo[name] = value
+	}
+
+	if sha1 != "" && sha1 != Sha1None {
+		fileInfo[LargeFileSha1InfoKey] = sha1
+	}
+
+	// B2 requires time to be in UNIX milliseconds
+	if mtime != nil {
+		fileInfo[SrcLastModifiedInfoKey] = fmt.Sprint(mtime.UnixNano() / 1000000)
+	}
+
+	largeFile, err := conn.StartLargeFile(b.ID, fileName, contentType, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep every part of the upload within this operation
+	largeFile.conn = conn
+
+	err = largeFile.uploadParts(data, fileSize, partSize)
+	if err != nil {
+		return nil, largeFile.abandon(err)
+	}
+
+	finished, err := largeFile.Finish()
+	if err != nil {
+		return nil, largeFile.abandon(err)
+	}
+
+	return finished, nil
+}
+
+// uploadParts reads fileSize bytes of data and uploads them in order as parts of partSize bytes
+func (l *LargeFile) uploadParts(data io.Reader, fileSize int64, partSize int64) error {
+	bufSize := partSize
+	if fileSize < bufSize {
+		bufSize = fileSize
+	}
+
+	buf := make([]byte, bufSize)
+	for partNumber := 1; fileSize > 0; partNumber++ {
+		size := partSize
+		if fileSize < size {
+			size = fileSize
+		}
+
+		_, err := io.ReadFull(data, buf[:size])
+		if err != nil {
+			return err
+		}
+
+		hash := sha1.Sum(buf[:size])
+		_, err = l.UploadPart(partNumber, bytes.NewReader(buf[:size]), size, hex.EncodeToString(hash[:]))
+		if err != nil {
+			return err
+		}
+
+		fileSize -= size
+	}
+
+	return nil
+}
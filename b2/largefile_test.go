@@ -0,0 +1,64 @@
+package b2
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadLargeFileSinglePart(t *testing.T) {
+	conn, fake := newFakeB2(t)
+	bucket := &Bucket{ID: "bucket", Name: "bucket", conn: conn}
+
+	info, err := bucket.UploadLargeFile(strings.NewReader("content"), "name", 7, 7, "", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.started != 0 || string(fake.uploads["name"]) != "content" {
+		t.Fatalf("expected a regular upload, got %d large files and uploads %q", fake.started, fake.uploads)
+	}
+
+	if info.Sha1 != "040f06fd774092478d450774f5ba30c5da78acc8" {
+		t.Fatalf("expected the SHA1 of the content, got %q", info.Sha1)
+	}
+}
+
+func TestUploadLargeFileParts(t *testing.T) {
+	conn, fake := newFakeB2(t)
+	bucket := &Bucket{ID: "bucket", Name: "bucket", conn: conn}
+
+	info, err := bucket.UploadLargeFile(strings.NewReader(strings.Repeat("a", 15)), "name", 15, 7, "", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.started != 1 || fake.parts != 3 || info.ID != "large" {
+		t.Fatalf("expected a large file of 3 parts, got %d large files of %d parts and %+v", fake.started, fake.parts, info)
+	}
+}
+
+func TestUploadLargeFileCancelAfterOperationTimeout(t *testing.T) {
+	fake := &fakeB2{uploads: map[string][]byte{}}
+	conn := newTestB2(t, func(w http.ResponseWriter, r *http.Request) {
+		if requestEndpoint(r) == "b2_upload_part" {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		fake.serveHTTP(w, r)
+	})
+	conn.OperationTimeout = 50 * time.Millisecond
+	bucket := &Bucket{ID: "bucket", Name: "bucket", conn: conn}
+
+	_, err := bucket.UploadLargeFile(strings.NewReader(strings.Repeat("a", 15)), "name", 15, 7, "", "", nil, nil)
+	if err == nil {
+		t.Fatal("expected the upload to time out")
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.cancelled != 1 {
+		t.Fatalf("expected the large file to be cancelled once the operation timed out, got %d cancellations", fake.cancelled)
+	}
+}
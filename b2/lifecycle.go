@@ -0,0 +1,21 @@
+package b2
+
+// LifecycleRule B2 rule hiding and deleting the files whose name starts with FileNamePrefix after a number of days.
+// A nil amount of days disables that step of the rule
+type LifecycleRule struct {
+	FileNamePrefix            string `json:"fileNamePrefix"`
+	DaysFromUploadingToHiding *int   `json:"daysFromUploadingToHiding"`
+	DaysFromHidingToDeleting  *int   `json:"daysFromHidingToDeleting"`
+
+	// DaysFromStartingToCancelingUnfinishedLargeFiles cancels the large files started this many days ago and still unfinished
+	DaysFromStartingToCancelingUnfinishedLargeFiles *int `json:"daysFromStartingToCancelingUnfinishedLargeFiles,omitempty"`
+}
+
+// lifecycleRules the rules of opts to send, nil when they are left unset so that an empty list still removes every rule
+func (o *BucketOptions) lifecycleRules() *[]LifecycleRule {
+	if o.LifecycleRules == nil {
+		return nil
+	}
+
+	return &o.LifecycleRules
+}
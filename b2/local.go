@@ -0,0 +1,139 @@
+package b2
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DefaultLargeFileThreshold size above which local files are uploaded as large files when neither
+// B2.LargeFileThreshold nor the recommended part size are known
+const DefaultLargeFileThreshold = 100 * 1000 * 1000
+
+// largeFileThreshold size above which files are uploaded as large files
+func (b *B2) largeFileThreshold() int64 {
+	if b.LargeFileThreshold > 0 {
+		return b.LargeFileThreshold
+	}
+
+	if b.RecommendedPartSize > 0 {
+		return b.RecommendedPartSize
+	}
+
+	return DefaultLargeFileThreshold
+}
+
+// UploadLocalFile uploads the file at localPath as remoteName, with its size, SHA1 and modification time taken from the file.
+// Files bigger than both the large file threshold and one part are uploaded in parts, with their SHA1 stored as LargeFileSha1InfoKey
+func (b *Bucket) UploadLocalFile(localPath string, remoteName string, info map[string]string) (*FileInfo, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	sha1, size, err := sha1Sum(file)
+	if err != nil {
+		return nil, err
+	}
+
+	mtime := stat.ModTime()
+
+	// a threshold below the part size would make large files of a single part, which B2 refuses to finish
+	partSize := b.conn.PartSizeFor(size)
+	if size > b.conn.largeFileThreshold() && size > partSize {
+		return b.UploadLargeFile(file, remoteName, size, partSize, "", sha1, &mtime, info)
+	}
+
+	return b.UploadFile(file, remoteName, size, "", sha1, &mtime, info)
+}
+
+// DownloadToFile downloads the latest version of remoteName to localPath, creating parent directories as needed.
+// The download goes to a temporary file that is only renamed into place once its SHA1 was verified,
+// and the modification time stored by uploads is restored
+func (b *Bucket) DownloadToFile(remoteName string, localPath string) (*FileInfo, error) {
+	dir := filepath.Dir(localPath)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := createTemp(dir, "."+filepath.Base(localPath)+".b2tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := b.downloadToTemp(tmp, remoteName)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	err = tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), localPath)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if mtime, ok := info.SrcLastModified(); ok {
+		err = os.Chtimes(localPath, mtime, mtime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// downloadToTemp downloads remoteName into tmp and verifies its SHA1
+func (b *Bucket) downloadToTemp(tmp *os.File, remoteName string) (*FileInfo, error) {
+	hash := sha1.New()
+	info, err := b.conn.DownloadFileByName(b.Name, remoteName, io.MultiWriter(tmp, hash))
+	if err != nil {
+		return nil, err
+	}
+
+	// decompressed downloads were verified on their compressed bytes already
+	if info.Decompressed {
+		return info, nil
+	}
+
+	// large files have no SHA1 of their own, but may carry the one given when they were started
+	expected := info.ContentSha1()
+	if expected != "" && expected != hex.EncodeToString(hash.Sum(nil)) {
+		return nil, ErrSha1Mismatch
+	}
+
+	return info, nil
+}
+
+// createTemp creates a new file in dir named prefix followed by a random number. Unlike ioutil.TempFile, which uses 0600,
+// it is created with 0666 minus the umask like os.Create, so the downloaded file gets the usual permissions once renamed
+func createTemp(dir string, prefix string) (*os.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+
+		return file, err
+	}
+
+	return nil, &os.PathError{Op: "createtemp", Path: filepath.Join(dir, prefix+"*"), Err: os.ErrExist}
+}
@@ -0,0 +1,29 @@
+package b2
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadLocalFileThresholdBelowPartSize(t *testing.T) {
+	conn, fake := newFakeB2(t)
+	conn.LargeFileThreshold = 4
+	conn.RecommendedPartSize = 10
+	bucket := &Bucket{ID: "bucket", Name: "bucket", conn: conn}
+
+	localPath := filepath.Join(t.TempDir(), "local")
+	err := ioutil.WriteFile(localPath, []byte("content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = bucket.UploadLocalFile(localPath, "name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.started != 0 || string(fake.uploads["name"]) != "content" {
+		t.Fatalf("expected a regular upload, got %d large files and uploads %q", fake.started, fake.uploads)
+	}
+}
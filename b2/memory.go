@@ -0,0 +1,77 @@
+package b2
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrDownloadTooLarge a file downloaded into memory is bigger than B2.MaxDownloadBytes
+var ErrDownloadTooLarge = errors.New("File is too large to download into memory")
+
+// DefaultMaxDownloadBytes the biggest file downloaded into memory when B2.MaxDownloadBytes is not set
+const DefaultMaxDownloadBytes = 16 * 1024 * 1024
+
+// maxDownloadBytes the biggest file downloaded into memory
+func (b *B2) maxDownloadBytes() int64 {
+	if b.MaxDownloadBytes > 0 {
+		return b.MaxDownloadBytes
+	}
+
+	return DefaultMaxDownloadBytes
+}
+
+// DownloadBytesByName downloads the latest version of fileName into memory.
+// Files bigger than MaxDownloadBytes fail with ErrDownloadTooLarge, before their content is read when B2 announces their length
+func (b *B2) DownloadBytesByName(bucketName string, fileName string) ([]byte, *FileInfo, error) {
+	output := &cappedBuffer{limit: b.maxDownloadBytes()}
+	info, err := b.DownloadFileByName(bucketName, fileName, output)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return output.Bytes(), info, nil
+}
+
+// DownloadBytesByID downloads one file into memory, failing with ErrDownloadTooLarge like DownloadBytesByName
+func (b *B2) DownloadBytesByID(fileID string) ([]byte, *FileInfo, error) {
+	output := &cappedBuffer{limit: b.maxDownloadBytes()}
+	info, err := b.DownloadFileByID(fileID, output)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return output.Bytes(), info, nil
+}
+
+// lengthChecker an output that download lets refuse a response by its Content-Length before its body is read
+type lengthChecker interface {
+	checkLength(length int64) error
+}
+
+// cappedBuffer a buffer refusing to grow past limit bytes
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int64
+}
+
+func (c *cappedBuffer) checkLength(length int64) error {
+	if length > c.limit {
+		return ErrDownloadTooLarge
+	}
+
+	// grow once instead of doubling along the download
+	if length > 0 {
+		c.Grow(int(length))
+	}
+
+	return nil
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	// decompressed downloads may grow past the announced length
+	if int64(c.Len()+len(p)) > c.limit {
+		return 0, ErrDownloadTooLarge
+	}
+
+	return c.Buffer.Write(p)
+}
@@ -0,0 +1,71 @@
+package b2
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// directions of the bytes reported to Metrics.AddBytes
+const (
+	MetricsDirectionUp   = "up"
+	MetricsDirectionDown = "down"
+)
+
+// Metrics receives observations about the requests sent to B2, such as to export them to Prometheus.
+// Its methods may be called concurrently
+type Metrics interface {
+	// ObserveRequest is called once per request sent, with the status of its response or 0 when it failed to be sent
+	ObserveRequest(endpoint string, status int, dur time.Duration)
+
+	// ObserveRetry is called every time a request to endpoint is sent again after a failure
+	ObserveRetry(endpoint string)
+
+	// AddBytes is called with the amount of bytes uploaded or downloaded, direction is MetricsDirectionUp or MetricsDirectionDown
+	AddBytes(direction string, n int64)
+}
+
+// requestEndpoint names the endpoint of a request, the B2 API call it makes, download_file_by_name for downloads by name or unknown
+func requestEndpoint(req *http.Request) string {
+	if strings.HasPrefix(req.URL.Path, "/file/") {
+		return "download_file_by_name"
+	}
+
+	// only the segment right after /b2api/vN/ names the call, later segments (upload URL tokens, file names) are not looked at
+	segments := strings.Split(req.URL.Path, "/")
+	for i := 0; i+2 < len(segments); i++ {
+		if segments[i] == "b2api" && strings.HasPrefix(segments[i+2], "b2_") {
+			return segments[i+2]
+		}
+	}
+
+	// never the path itself, it carries bucket and file names that would blow up label cardinality
+	return "unknown"
+}
+
+// observeRequest reports a request to the Metrics of this connection, if any
+func (b *B2) observeRequest(req *http.Request, status int, dur time.Duration) {
+	if b == nil || b.Metrics == nil {
+		return
+	}
+
+	b.Metrics.ObserveRequest(requestEndpoint(req), status, dur)
+}
+
+// observeRetry reports a retried request to the Metrics of this connection, if any
+func (b *B2) observeRetry(endpoint string) {
+	if b == nil || b.Metrics == nil {
+		return
+	}
+
+	b.Metrics.ObserveRetry(endpoint)
+}
+
+// addBytes reports transferred bytes to the Metrics of this connection, if any
+func (b *B2) addBytes(direction string, n int64) {
+	if b == nil || b.Metrics == nil || n <= 0 {
+		return
+	}
+
+	b.Metrics.AddBytes(direction, n)
+}
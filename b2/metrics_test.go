@@ -0,0 +1,29 @@
+package b2
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequestEndpoint(t *testing.T) {
+	endpoints := map[string]string{
+		"https://api.example.com/b2api/v1/b2_list_buckets":                  "b2_list_buckets",
+		"https://pod.example.com/b2api/v1/b2_upload_file/bucket/b2_token":   "b2_upload_file",
+		"https://f001.example.com/b2api/v1/b2_download_file_by_id?fileId=x": "b2_download_file_by_id",
+		"https://f001.example.com/file/bucket/b2_backup.tar":                "download_file_by_name",
+		"https://f001.example.com/file/b2_bucket/name":                      "download_file_by_name",
+		"https://api.example.com/bucket/b2_name":                            "unknown",
+		"https://api.example.com/b2api/v1/bucket/b2_name":                   "unknown",
+	}
+
+	for rawURL, expected := range endpoints {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if endpoint := requestEndpoint(req); endpoint != expected {
+			t.Errorf("%s: expected %s, got %s", rawURL, expected, endpoint)
+		}
+	}
+}
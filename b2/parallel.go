@@ -0,0 +1,149 @@
+package b2
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrRangeOverflow a ranged download returned more bytes than the range asked for, such as when the range was ignored
+var ErrRangeOverflow = errors.New("Download returned more bytes than the requested range")
+
+// minDownloadRangeSize the smallest range DownloadFileConcurrent splits a file into
+const minDownloadRangeSize = 1024 * 1024
+
+// downloadRangeRetries how many times a failed range of DownloadFileConcurrent is retried when MaxRetries is lower
+const downloadRangeRetries = 3
+
+// DownloadFileConcurrent downloads one file from B2 as concurrency ranged downloads at once, each written at its offset of w.
+// A failed range is resumed where it stopped, up to MaxRetries or 3 times, and the first range failing for good cancels the others.
+// The SHA1 is not verified since the ranges are not read in order, the total amount of bytes written is
+func (b *B2) DownloadFileConcurrent(fileID string, w io.WriterAt, concurrency int) (*FileInfo, error) {
+	if concurrency < 1 {
+		return nil, ErrInvalidConcurrency
+	}
+
+	conn, cancel := b.operation()
+	defer cancel()
+
+	info, err := conn.GetFileInfo(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	rangeSize := (info.Length + int64(concurrency) - 1) / int64(concurrency)
+	if rangeSize < minDownloadRangeSize {
+		rangeSize = minDownloadRangeSize
+	}
+
+	ranges := make(chan *offsetWriter)
+
+	// the first failure is kept, the ones after it come from cancelling the other ranges
+	var firstErr error
+	var failed sync.Once
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range ranges {
+				err := conn.downloadRange(fileID, part)
+				if err != nil {
+					failed.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	var parts []*offsetWriter
+	for start := int64(0); start < info.Length; start += rangeSize {
+		end := start + rangeSize
+		if end > info.Length {
+			end = info.Length
+		}
+
+		part := &offsetWriter{w: w, start: start, end: end}
+		parts = append(parts, part)
+		ranges <- part
+	}
+	close(ranges)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	var total int64
+	for _, part := range parts {
+		total += part.written
+	}
+
+	if total != info.Length {
+		return nil, ErrShortRead
+	}
+
+	return info, nil
+}
+
+// downloadRange downloads the bytes of part, resuming after the bytes already written when a download fails
+func (b *B2) downloadRange(fileID string, part *offsetWriter) error {
+	retries := b.MaxRetries
+	if retries < downloadRangeRetries {
+		retries = downloadRangeRetries
+	}
+
+	for retry := 1; ; retry++ {
+		_, err := b.DownloadFileRangeByID(fileID, part.start+part.written, part.end-1, part)
+		if err == nil && part.written != part.end-part.start {
+			err = ErrShortRead
+		}
+
+		if err == nil || retry > retries || !rangeRetryable(b.context(), err) {
+			return err
+		}
+
+		if sleepErr := b.sleep(b.retryDelay(err, retry)); sleepErr != nil {
+			return err
+		}
+
+		b.observeRetry("b2_download_file_by_id")
+	}
+}
+
+// rangeRetryable tells whether downloading a range again may fix err, unlike requests the body may also fail while read
+func rangeRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrRangeOverflow) {
+		return false
+	}
+
+	var b2Err *Err
+	if errors.As(err, &b2Err) {
+		return retryable(err)
+	}
+
+	return true
+}
+
+// offsetWriter writes the bytes of the range from start to end, exclusive, at their offset of w
+type offsetWriter struct {
+	w       io.WriterAt
+	start   int64
+	end     int64
+	written int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > o.end-o.start-o.written {
+		return 0, ErrRangeOverflow
+	}
+
+	n, err := o.w.WriteAt(p, o.start+o.written)
+	o.written += int64(n)
+
+	return n, err
+}
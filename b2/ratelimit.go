@@ -0,0 +1,139 @@
+package b2
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter token bucket limiting the bytes per second of every transfer sharing it
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing bytesPerSec bytes per second, with a burst of one second worth of bytes
+func NewRateLimiter(bytesPerSec int64) *RateLimiter {
+	if bytesPerSec < 1 {
+		bytesPerSec = 1
+	}
+
+	return &RateLimiter{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   time.Now(),
+	}
+}
+
+// burst the largest amount of bytes a single wait may reserve
+func (l *RateLimiter) burst() int {
+	return int(l.rate)
+}
+
+// wait reserves n bytes and blocks until they may be transferred or ctx is done
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	// tokens may go negative, which queues concurrent callers behind each other
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitedReader io.Reader throttled by a RateLimiter
+type limitedReader struct {
+	r       io.Reader
+	limiter *RateLimiter
+	ctx     context.Context
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.burst(); len(p) > burst {
+		p = p[:burst]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+// limitedWriter io.Writer throttled by a RateLimiter
+type limitedWriter struct {
+	w       io.Writer
+	limiter *RateLimiter
+	ctx     context.Context
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if burst := w.limiter.burst(); len(chunk) > burst {
+			chunk = chunk[:burst]
+		}
+
+		err := w.limiter.wait(w.ctx, len(chunk))
+		if err != nil {
+			return written, err
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// limitReader throttles r by the RateLimit of this connection, if any
+func (b *B2) limitReader(r io.Reader) io.Reader {
+	if b == nil || b.RateLimit == nil {
+		return r
+	}
+
+	return &limitedReader{r: r, limiter: b.RateLimit, ctx: b.context()}
+}
+
+// limitWriter throttles w by the RateLimit of this connection, if any
+func (b *B2) limitWriter(w io.Writer) io.Writer {
+	if b == nil || b.RateLimit == nil {
+		return w
+	}
+
+	return &limitedWriter{w: w, limiter: b.RateLimit, ctx: b.context()}
+}
@@ -0,0 +1,117 @@
+package b2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ErrInvalidOffset a negative offset was given to ReadAt
+var ErrInvalidOffset = errors.New("Invalid offset for ReadAt")
+
+// ErrRangeIgnored a ranged download was answered with the whole file
+var ErrRangeIgnored = errors.New("Range of the download was ignored")
+
+// ReaderAt provides random access to one B2 file by issuing a ranged download per ReadAt call
+type ReaderAt struct {
+	conn *B2
+	url  string
+	size int64
+}
+
+// NewReaderAt creates a ReaderAt for the latest version of fileName in this bucket and returns the total size of the file.
+// Reads stay on that version by its file ID, even if fileName is uploaded again in the meantime
+func (b *Bucket) NewReaderAt(fileName string) (*ReaderAt, int64, error) {
+	info, err := b.conn.GetFileInfoByName(b.Name, fileName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	downloadURL, err := b.conn.downloadURL()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fileURL := downloadURL + APIsuffix + "/b2_download_file_by_id?" + url.Values{"fileId": {info.ID}}.Encode()
+
+	return &ReaderAt{
+		conn: b.conn,
+		url:  fileURL,
+		size: info.Length,
+	}, info.Length, nil
+}
+
+// Size returns the total size of the file in bytes
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes of the file starting at off, implementing io.ReaderAt.
+// It fails with ErrRangeIgnored when the whole file is sent back for a read not starting at 0
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := off + int64(len(p)) - 1
+	if end >= r.size {
+		end = r.size - 1
+	}
+
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Add("Authorization", r.conn.AuthToken)
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", off, end))
+
+	err = r.conn.encrypt(req)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := r.conn.do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	body := r.conn.limitReader(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case GoodStatus:
+		// the range was ignored and the whole file is being sent, which is only what was asked for from the start,
+		// skipping to the offset would download everything before it again on every call
+		if off > 0 {
+			resp.Body.Close()
+			return 0, ErrRangeIgnored
+		}
+	default:
+		return 0, r.conn.readResp(resp, nil)
+	}
+
+	defer resp.Body.Close()
+
+	n, err := io.ReadFull(body, p[:end-off+1])
+	r.conn.addBytes(MetricsDirectionDown, int64(n))
+	if err != nil {
+		return n, err
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
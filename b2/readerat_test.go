@@ -0,0 +1,27 @@
+package b2
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestReadAtRangeIgnored(t *testing.T) {
+	conn := newTestB2(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Bz-File-Id", "id")
+		w.Header().Set("X-Bz-File-Name", "name")
+		w.Write([]byte("content"))
+	})
+	reader := &ReaderAt{conn: conn, url: conn.DownloadURL + APIsuffix + "/b2_download_file_by_id?fileId=id", size: 7}
+
+	p := make([]byte, 3)
+	n, err := reader.ReadAt(p, 0)
+	if err != nil || string(p[:n]) != "con" {
+		t.Fatalf("expected the start of the file, got %q, %v", p[:n], err)
+	}
+
+	_, err = reader.ReadAt(p, 3)
+	if !errors.Is(err, ErrRangeIgnored) {
+		t.Fatalf("expected ErrRangeIgnored, got %v", err)
+	}
+}
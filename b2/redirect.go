@@ -0,0 +1,40 @@
+package b2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxRedirects the most redirects KeepAuthorizationRedirects follows, matching net/http
+const maxRedirects = 10
+
+// RedirectError a download was answered with a redirect that was not followed
+type RedirectError struct {
+	Status   int
+	Location string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirected with status '%d' to '%s'", e.Status, e.Location)
+}
+
+// NoRedirects http.Client CheckRedirect policy that never follows redirects, downloads then return a *RedirectError holding the Location
+func NoRedirects(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// KeepAuthorizationRedirects http.Client CheckRedirect policy that follows redirects and sends the Authorization header again.
+// net/http drops it once a redirect leaves the original host, which breaks CDNs fronting B2.
+// Only use it when every host redirected to is trusted with the B2 authorization token
+func KeepAuthorizationRedirects(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after too many redirects")
+	}
+
+	if auth := via[0].Header.Get("Authorization"); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package b2
+
+// ReplicationConfiguration B2 replication of a bucket, as the source of rules and/or the destination of other buckets
+type ReplicationConfiguration struct {
+	AsReplicationSource      *ReplicationSource      `json:"asReplicationSource,omitempty"`
+	AsReplicationDestination *ReplicationDestination `json:"asReplicationDestination,omitempty"`
+}
+
+// ReplicationSource B2 replication rules of a source bucket and the application key replicating with
+type ReplicationSource struct {
+	ReplicationRules       []ReplicationRule `json:"replicationRules"`
+	SourceApplicationKeyID string            `json:"sourceApplicationKeyId"`
+}
+
+// ReplicationRule B2 rule replicating the files of a source bucket matching FileNamePrefix to a destination bucket
+type ReplicationRule struct {
+	DestinationBucketID  string `json:"destinationBucketId"`
+	FileNamePrefix       string `json:"fileNamePrefix"`
+	IncludeExistingFiles bool   `json:"includeExistingFiles"`
+	IsEnabled            bool   `json:"isEnabled"`
+	Priority             int    `json:"priority"`
+	ReplicationRuleName  string `json:"replicationRuleName"`
+}
+
+// ReplicationDestination B2 mapping of source application key IDs to the destination key IDs they replicate with
+type ReplicationDestination struct {
+	SourceToDestinationKeyMapping map[string]string `json:"sourceToDestinationKeyMapping"`
+}
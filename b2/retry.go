@@ -0,0 +1,150 @@
+package b2
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrNotSeekable retries are enabled but the data of an upload can neither be rewound nor buffered
+var ErrNotSeekable = errors.New("Upload data must be an io.ReadSeeker to be retried")
+
+// DefaultRetryBufferSize the largest upload whose data is buffered in memory to be retried when it is not an io.ReadSeeker
+// and B2.RetryBufferSize is not set
+const DefaultRetryBufferSize = 5 * 1000 * 1000
+
+// Backoff decides how long to wait before retrying, attempt counts the retries from 1
+type Backoff interface {
+	NextDelay(attempt int) time.Duration
+}
+
+// ExponentialBackoff waits a random delay between zero and Min doubled for each attempt, up to Max, known as full jitter.
+// The randomness keeps many clients failing at once from retrying at once
+type ExponentialBackoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// DefaultBackoff the Backoff used when B2.Backoff is not set
+var DefaultBackoff Backoff = &ExponentialBackoff{Min: time.Second, Max: 30 * time.Second}
+
+// NextDelay a random delay up to Min * 2^(attempt-1), capped to Max
+func (e *ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	ceiling := e.Min
+	for i := 1; i < attempt && ceiling < e.Max; i++ {
+		ceiling *= 2
+	}
+
+	if ceiling > e.Max {
+		ceiling = e.Max
+	}
+
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// backoff the Backoff retries wait with
+func (b *B2) backoff() Backoff {
+	if b.Backoff != nil {
+		return b.Backoff
+	}
+
+	return DefaultBackoff
+}
+
+// retryBufferSize the largest non seekable upload buffered to be retried
+func (b *B2) retryBufferSize() int64 {
+	if b.RetryBufferSize > 0 {
+		return b.RetryBufferSize
+	}
+
+	return DefaultRetryBufferSize
+}
+
+// retryable tells whether sending a request again, with a new upload URL, may fix err
+func retryable(err error) bool {
+	// a cap stays exceeded until the account changes, retrying would only cost more transactions
+	if errors.Is(err, ErrCapExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrExpiredAuthToken) {
+		return true
+	}
+
+	var b2Err *Err
+	if errors.As(err, &b2Err) {
+		return b2Err.Status == http.StatusRequestTimeout || b2Err.Status == http.StatusTooManyRequests || b2Err.Status >= 500
+	}
+
+	// the connection failed before B2 answered
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !urlErr.Timeout()
+}
+
+// retryDelay how long to wait before the given retry, counting from 1, of err
+func (b *B2) retryDelay(err error, retry int) time.Duration {
+	// an expired token is fixed by the new upload URL itself
+	if errors.Is(err, ErrExpiredAuthToken) {
+		return 0
+	}
+
+	return b.backoff().NextDelay(retry)
+}
+
+// sleep waits for d or until the context of the connection is done
+func (b *B2) sleep(d time.Duration) error {
+	ctx := b.context()
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replayable makes data of size bytes readable again for retries, returning the reader to upload and a func rewinding it.
+// An io.ReadSeeker is seeked back, other data is buffered if it is small enough, else ErrNotSeekable is returned
+func (b *B2) replayable(data io.Reader, size int64) (io.Reader, func() error, error) {
+	if seeker, ok := data.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return seeker, func() error {
+			_, err := seeker.Seek(start, io.SeekStart)
+			return err
+		}, nil
+	}
+
+	if size > b.retryBufferSize() {
+		return nil, nil, ErrNotSeekable
+	}
+
+	buf := make([]byte, size)
+	_, err := io.ReadFull(data, buf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bytes.NewReader(buf)
+	return reader, func() error {
+		_, err := reader.Seek(0, io.SeekStart)
+		return err
+	}, nil
+}
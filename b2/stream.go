@@ -0,0 +1,66 @@
+package b2
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+)
+
+// UploadStream uploads data of unknown size as fileName, holding one part in memory at a time.
+// B2 requires a Content-Length on both b2_upload_file and b2_upload_part and refuses chunked requests, so the data is cut
+// in parts of partSize bytes (PartSizeFor an unknown size when 0) sent with their exact length instead.
+// Data fitting in a single part is uploaded as a regular file, anything bigger as a large file
+func (b *Bucket) UploadStream(data io.Reader, fileName string, partSize int64, contentType string, info map[string]string) (*FileInfo, error) {
+	conn, cancel := b.conn.operation()
+	defer cancel()
+
+	if partSize <= 0 {
+		partSize = conn.PartSizeFor(0)
+	}
+
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(data, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	// a full first part is followed by a large file only if there is more data, B2 refuses large files of a single part
+	var next [1]byte
+	if err == nil {
+		_, err = io.ReadFull(data, next[:])
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
+	if err != nil {
+		digest := sha1.Sum(buf[:n])
+		return b.UploadFile(bytes.NewReader(buf[:n]), fileName, int64(n), contentType, hex.EncodeToString(digest[:]), nil, info)
+	}
+
+	data = io.MultiReader(bytes.NewReader(next[:]), data)
+
+	largeFile, err := conn.StartLargeFile(b.ID, fileName, contentType, b.fileInfo(info))
+	if err != nil {
+		return nil, err
+	}
+
+	// keep every part of the upload within this operation
+	largeFile.conn = conn
+
+	for partNumber := 1; n > 0; partNumber++ {
+		digest := sha1.Sum(buf[:n])
+		_, err = largeFile.UploadPart(partNumber, bytes.NewReader(buf[:n]), int64(n), hex.EncodeToString(digest[:]))
+		if err != nil {
+			return nil, largeFile.abandon(err)
+		}
+
+		n, err = io.ReadFull(data, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, largeFile.abandon(err)
+		}
+	}
+
+	return largeFile.Finish()
+}
@@ -0,0 +1,40 @@
+package b2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUploadStream(t *testing.T) {
+	sizes := map[int]int{
+		0:  0,
+		6:  0,
+		7:  0,
+		8:  2,
+		14: 2,
+		15: 3,
+	}
+
+	for size, parts := range sizes {
+		conn, fake := newFakeB2(t)
+		bucket := &Bucket{ID: "bucket", Name: "bucket", conn: conn}
+
+		_, err := bucket.UploadStream(strings.NewReader(strings.Repeat("a", size)), "name", 7, "", nil)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+
+		started := 0
+		if parts > 0 {
+			started = 1
+		}
+
+		if fake.parts != parts || fake.started != started {
+			t.Errorf("%d bytes: expected %d parts, got %d parts of %d large files", size, parts, fake.parts, fake.started)
+		}
+
+		if parts == 0 && len(fake.uploads["name"]) != size {
+			t.Errorf("%d bytes: expected a regular upload, got %q", size, fake.uploads)
+		}
+	}
+}
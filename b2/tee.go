@@ -0,0 +1,184 @@
+package b2
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"os"
+)
+
+// TeeWriter writes data both to a local file and to a B2 large file as it is written, so the data is only produced once.
+// Parts are uploaded as soon as they are full, with their SHA1 computed from the buffered part.
+// A failure on either side removes the local file and cancels the large file
+type TeeWriter struct {
+	bucket    *Bucket
+	conn      *B2
+	cancel    func()
+	file      *os.File
+	largeFile *LargeFile
+	name      string
+
+	buf        []byte
+	partNumber int
+	hash       hash.Hash
+	info       *FileInfo
+	err        error
+}
+
+// NewTeeWriter creates localPath and starts the large file remoteName, both receiving what is written to the returned TeeWriter.
+// partSize is PartSizeFor an unknown size when 0, Close finishes both
+func (b *Bucket) NewTeeWriter(localPath string, remoteName string, partSize int64, contentType string, info map[string]string) (*TeeWriter, error) {
+	conn, cancel := b.conn.operation()
+
+	if partSize <= 0 {
+		partSize = conn.PartSizeFor(0)
+	}
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	largeFile, err := conn.StartLargeFile(b.ID, remoteName, contentType, b.fileInfo(info))
+	if err != nil {
+		file.Close()
+		os.Remove(localPath)
+		cancel()
+		return nil, err
+	}
+
+	largeFile.conn = conn
+
+	return &TeeWriter{
+		bucket:     b,
+		conn:       conn,
+		cancel:     cancel,
+		file:       file,
+		largeFile:  largeFile,
+		name:       remoteName,
+		buf:        make([]byte, 0, partSize),
+		partNumber: 1,
+		hash:       sha1.New(),
+	}, nil
+}
+
+// Write writes p to the local file and buffers it for the next part, uploading the buffered part once it is full
+func (t *TeeWriter) Write(p []byte) (int, error) {
+	if t.info != nil {
+		return 0, os.ErrClosed
+	}
+
+	if t.err != nil {
+		return 0, t.err
+	}
+
+	n, err := t.file.Write(p)
+	if err != nil {
+		return n, t.abort(err)
+	}
+
+	t.hash.Write(p)
+	written := 0
+	for len(p) > 0 {
+		// a full part is only uploaded once more data follows, so Close knows whether there is more than one part
+		if len(t.buf) == cap(t.buf) {
+			err = t.flush()
+			if err != nil {
+				return written, err
+			}
+		}
+
+		free := cap(t.buf) - len(t.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+
+		t.buf = append(t.buf, p[:free]...)
+		p = p[free:]
+		written += free
+	}
+
+	return written, nil
+}
+
+// flush uploads the buffered part
+func (t *TeeWriter) flush() error {
+	digest := sha1.Sum(t.buf)
+	_, err := t.largeFile.UploadPart(t.partNumber, bytes.NewReader(t.buf), int64(len(t.buf)), hex.EncodeToString(digest[:]))
+	if err != nil {
+		return t.abort(err)
+	}
+
+	t.partNumber++
+	t.buf = t.buf[:0]
+	return nil
+}
+
+// abort rolls back both sides after err, returning err along with the failure to cancel the large file if any
+func (t *TeeWriter) abort(err error) error {
+	t.err = t.largeFile.abandon(err)
+	t.file.Close()
+	os.Remove(t.file.Name())
+	t.cancel()
+	return t.err
+}
+
+// Abort discards what was written, removing the local file and cancelling the large file.
+// It does nothing once Close succeeded, so it can be deferred right after NewTeeWriter
+func (t *TeeWriter) Abort() {
+	if t.err == nil && t.info == nil {
+		t.abort(os.ErrClosed)
+	}
+}
+
+// Close uploads the last part, finishes the large file and closes the local file.
+// Data that fits in a single part, none at all included, is uploaded as a regular file instead
+func (t *TeeWriter) Close() error {
+	if t.info != nil {
+		return nil
+	}
+
+	if t.err != nil {
+		return t.err
+	}
+
+	err := t.file.Close()
+	if err != nil {
+		return t.abort(err)
+	}
+
+	if t.partNumber == 1 {
+		// B2 refuses to finish a large file of less than 2 parts, what fits in one part is uploaded as a regular file
+		err = t.largeFile.cancelDetached()
+		if err == nil {
+			digest := sha1.Sum(t.buf)
+			t.info, err = t.bucket.UploadFile(bytes.NewReader(t.buf), t.name, int64(len(t.buf)), t.largeFile.Type, hex.EncodeToString(digest[:]), nil, t.largeFile.Info)
+		}
+	} else {
+		err = t.flush()
+		if err != nil {
+			return err
+		}
+
+		t.info, err = t.largeFile.Finish()
+	}
+
+	if err != nil {
+		return t.abort(err)
+	}
+
+	t.cancel()
+	return nil
+}
+
+// Sha1 the hex SHA1 of everything written so far, B2 cannot store it on the large file since its info is set when it starts
+func (t *TeeWriter) Sha1() string {
+	return hex.EncodeToString(t.hash.Sum(nil))
+}
+
+// FileInfo the file uploaded to B2, nil until Close succeeded
+func (t *TeeWriter) FileInfo() *FileInfo {
+	return t.info
+}
@@ -0,0 +1,68 @@
+package b2
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestTeeWriterSinglePart(t *testing.T) {
+	// data smaller than a part, and data of exactly one part
+	for _, partSize := range []int64{1000, int64(len("content"))} {
+		conn, fake := newFakeB2(t)
+		bucket := &Bucket{ID: "bucket", Name: "bucket", conn: conn}
+		localPath := filepath.Join(t.TempDir(), "local")
+
+		tee, err := bucket.NewTeeWriter(localPath, "name", partSize, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tee.Write([]byte("content"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = tee.Close()
+		if err != nil {
+			t.Fatalf("part size %d: %v", partSize, err)
+		}
+
+		local, err := ioutil.ReadFile(localPath)
+		if err != nil || string(local) != "content" {
+			t.Fatalf("part size %d: expected the local file to be kept, got %q, %v", partSize, local, err)
+		}
+
+		if fake.cancelled != 1 || fake.parts != 0 || string(fake.uploads["name"]) != "content" {
+			t.Fatalf("part size %d: expected a cancelled large file and a regular upload, got %d cancelled, %d parts and uploads %q", partSize, fake.cancelled, fake.parts, fake.uploads)
+		}
+
+		if tee.FileInfo().Length != int64(len("content")) {
+			t.Fatalf("part size %d: unexpected file info %+v", partSize, tee.FileInfo())
+		}
+	}
+}
+
+func TestTeeWriterParts(t *testing.T) {
+	conn, fake := newFakeB2(t)
+	bucket := &Bucket{ID: "bucket", Name: "bucket", conn: conn}
+
+	tee, err := bucket.NewTeeWriter(filepath.Join(t.TempDir(), "local"), "name", 4, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tee.Write([]byte("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tee.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.parts != 2 || fake.cancelled != 0 || tee.FileInfo().ID != "large" {
+		t.Fatalf("expected a large file of 2 parts, got %d parts, %d cancelled and %+v", fake.parts, fake.cancelled, tee.FileInfo())
+	}
+}
@@ -1,8 +1,14 @@
 package b2
 
 import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"time"
@@ -13,11 +19,173 @@ type Upload struct {
 	BucketID  string `json:"bucketId"`
 	UploadURL string `json:"uploadUrl"`
 	AuthToken string `json:"authorizationToken"`
+	conn      *B2
+	obtained  time.Time
+}
+
+// Sha1AtEnd SHA1 header value telling B2 the 40 hex digits of the SHA1 follow the file's content
+const Sha1AtEnd = "hex_digits_at_end"
+
+// EmptySha1 the hex SHA1 of empty content, used for zero byte uploads without a SHA1
+const EmptySha1 = "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+
+// sha1HexLength the length of a SHA1 in hex
+const sha1HexLength = 40
+
+// DefaultUploadURLTTL how long an upload URL is reused when B2.UploadURLTTL is not set, B2 upload tokens last up to 24 hours
+const DefaultUploadURLTTL = 12 * time.Hour
+
+// PutOptions optional parameters for the higher level upload helpers
+type PutOptions struct {
+	ContentType string
+	Mtime       *time.Time
+	Info        map[string]string
+
+	// ComputeMd5 computes the MD5 of the content along with its SHA1 and stores it in the file info as Md5InfoKey
+	ComputeMd5 bool
+
+	// ContentMd5 the base64 MD5 of the content sent as the Content-MD5 header, SendContentMd5 computes it instead.
+	// B2's native API ignores the header, it is only checked by intermediaries such as proxies or B2's S3 compatible gateway
+	ContentMd5     string
+	SendContentMd5 bool
+
+	// headers B2 serves back on download, stored as the matching InfoKey, they win over the same keys in Info
+	ContentDisposition string
+	CacheControl       string
+	Expires            *time.Time
+	ContentEncoding    string
+	ContentLanguage    string
+}
+
+// contentMd5 the Content-MD5 header to send, md5 being the hex MD5 computed for SendContentMd5
+func (o *PutOptions) contentMd5(md5 string) (string, error) {
+	if o.ContentMd5 != "" {
+		return o.ContentMd5, nil
+	}
+
+	if !o.SendContentMd5 {
+		return "", nil
+	}
+
+	digest, err := hex.DecodeString(md5)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(digest), nil
+}
+
+// InvalidInfoError a file info value B2 would refuse or could not serve back as a header
+type InvalidInfoError struct {
+	Key   string
+	Value string
+}
+
+func (e *InvalidInfoError) Error() string {
+	return fmt.Sprintf("invalid value for file info '%s': %q", e.Key, e.Value)
+}
+
+// info the file info of the upload, Info with the header fields set over it
+func (o *PutOptions) info() (map[string]string, error) {
+	headers := map[string]string{
+		InfoKeyContentDisposition: o.ContentDisposition,
+		InfoKeyCacheControl:       o.CacheControl,
+		InfoKeyContentEncoding:    o.ContentEncoding,
+		InfoKeyContentLanguage:    o.ContentLanguage,
+	}
+
+	if o.Expires != nil {
+		headers[InfoKeyExpires] = o.Expires.UTC().Format(http.TimeFormat)
+	}
+
+	info := o.Info
+	for key, value := range headers {
+		if value == "" {
+			continue
+		}
+
+		if !validHeaderValue(value) {
+			return nil, &InvalidInfoError{Key: key, Value: value}
+		}
+
+		if key == InfoKeyContentDisposition {
+			_, _, err := mime.ParseMediaType(value)
+			if err != nil {
+				return nil, &InvalidInfoError{Key: key, Value: value}
+			}
+		}
+
+		info = withInfo(info, key, value)
+	}
+
+	return info, nil
+}
+
+// validHeaderValue tells whether value only holds printable ASCII, so it can be sent and served back as a header
+func validHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < ' ' || value[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PreparedUpload what a client, such as a browser, needs to upload a file directly to B2, it is meant to be sent as JSON.
+// The client POSTs the content to UploadURL with AuthToken as the Authorization header and every header of RequiredHeaders,
+// the file name URL encoded. The token is valid for up to 24 hours
+type PreparedUpload struct {
+	BucketID        string   `json:"bucketId"`
+	UploadURL       string   `json:"uploadUrl"`
+	AuthToken       string   `json:"authorizationToken"`
+	RequiredHeaders []string `json:"requiredHeaders"`
+}
+
+// String describes the prepared upload with its authorization token redacted, the JSON encoding keeps it
+func (p PreparedUpload) String() string {
+	return fmt.Sprintf("{BucketID:%s UploadURL:%s AuthToken:%s RequiredHeaders:%v}", p.BucketID, p.UploadURL, redact(p.AuthToken), p.RequiredHeaders)
+}
+
+// preparedUploadHeaders the headers a client must set besides Authorization to upload a file
+var preparedUploadHeaders = []string{"X-Bz-File-Name", "X-Bz-Content-Sha1", "Content-Type", "Content-Length"}
+
+// String describes the upload URL with its authorization token redacted
+func (u Upload) String() string {
+	return fmt.Sprintf("{BucketID:%s UploadURL:%s AuthToken:%s}", u.BucketID, u.UploadURL, redact(u.AuthToken))
+}
+
+// GoString describes the upload URL with its authorization token redacted for the %#v verb
+func (u Upload) GoString() string {
+	return fmt.Sprintf("b2.Upload{BucketID:%q, UploadURL:%q, AuthToken:%q}", u.BucketID, u.UploadURL, redact(u.AuthToken))
 }
 
 // UploadFile uploads one file to B2
 func (u *Upload) UploadFile(data io.Reader, fileName string, fileSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string) (*FileInfo, error) {
-	req, err := http.NewRequest("POST", u.UploadURL, data)
+	return u.uploadFile(data, fileName, fileSize, contentType, sha1, mtime, info, nil)
+}
+
+// uploadFile uploads one file to B2 like UploadFile, with header added to the request
+func (u *Upload) uploadFile(data io.Reader, fileName string, fileSize int64, contentType string, sha1 string, mtime *time.Time, info map[string]string, header http.Header) (*FileInfo, error) {
+	err := u.conn.checkNamePrefix(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.conn != nil && u.conn.DryRun {
+		u.conn.plan("b2_upload_file", map[string]string{"bucketId": u.BucketID, "fileName": fileName, "contentSha1": sha1})
+		return &FileInfo{Name: fileName, BucketID: u.BucketID, Length: fileSize, Sha1: sha1, Type: contentType, Info: info, conn: u.conn.base()}, nil
+	}
+
+	// a body with a zero length would be sent chunked, which B2 rejects
+	var body io.Reader = http.NoBody
+	if fileSize != 0 {
+		body = u.conn.limitReader(data)
+	} else if sha1 == "" {
+		sha1 = EmptySha1
+	}
+
+	req, err := http.NewRequest("POST", u.UploadURL, body)
 	if err != nil {
 		return nil, err
 	}
@@ -42,10 +210,19 @@ func (u *Upload) UploadFile(data io.Reader, fileName string, fileSize int64, con
 	req.Header.Add("X-Bz-File-Name", fileName)
 	req.Header.Add("Content-Type", contentType)
 	req.Header.Add("X-Bz-Content-Sha1", sha1)
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	if u.conn != nil && u.conn.ExpectContinue && fileSize > 0 {
+		req.Header.Set("Expect", "100-continue")
+	}
 
 	// B2 requires time to be in UNIX milliseconds
 	if mtime != nil {
-		req.Header.Add("X-Bz-Info-src_last_modified_millis", fmt.Sprint(mtime.UnixNano()/1000000))
+		req.Header.Add(HeaderInfoPrefix+SrcLastModifiedInfoKey, fmt.Sprint(mtime.UnixNano()/1000000))
 	}
 
 	if info != nil {
@@ -54,16 +231,153 @@ func (u *Upload) UploadFile(data io.Reader, fileName string, fileSize int64, con
 		}
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := u.conn.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	fileInfo := &FileInfo{}
-	err = readResp(resp, fileInfo)
+	fileInfo := &FileInfo{conn: u.conn.base()}
+	err = u.conn.readResp(resp, fileInfo)
 	if err != nil {
 		return nil, err
 	}
 
 	return fileInfo, nil
 }
+
+// uploadURLTTL how long an upload URL may be reused
+func (b *B2) uploadURLTTL() time.Duration {
+	if b.UploadURLTTL > 0 {
+		return b.UploadURLTTL
+	}
+
+	return DefaultUploadURLTTL
+}
+
+// acquireUpload takes an idle upload URL for bucketID that has not outlived UploadURLTTL, or gets a new one.
+// B2 upload URLs must only be used by one upload at a time
+func (b *B2) acquireUpload(bucketID string) (*Upload, error) {
+	ttl := b.uploadURLTTL()
+	state := b.state()
+
+	state.mu.Lock()
+	idle := state.uploads[bucketID]
+	for len(idle) > 0 {
+		upload := idle[len(idle)-1]
+		idle = idle[:len(idle)-1]
+
+		if time.Since(upload.obtained) < ttl {
+			state.uploads[bucketID] = idle
+			state.mu.Unlock()
+			return upload, nil
+		}
+	}
+	delete(state.uploads, bucketID)
+	state.mu.Unlock()
+
+	return b.GetUploadURL(bucketID)
+}
+
+// releaseUpload returns an upload URL to the idle pool once an upload with it succeeded
+func (b *B2) releaseUpload(upload *Upload) {
+	state := b.state()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.closed {
+		return
+	}
+
+	if state.uploads == nil {
+		state.uploads = map[string][]*Upload{}
+	}
+
+	state.uploads[upload.BucketID] = append(state.uploads[upload.BucketID], upload)
+}
+
+// discardUploadHost drops the idle upload URLs of the bucket of upload that point to the same host as it.
+// B2 answers 503 or 429 when the pod behind an upload URL is busy or unavailable, the other URLs it handed out for that pod
+// would fail the same way, so retries get a URL of another pod instead
+func (b *B2) discardUploadHost(upload *Upload) {
+	host := uploadHost(upload.UploadURL)
+
+	state := b.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	idle := state.uploads[upload.BucketID]
+	kept := idle[:0]
+	for _, pooled := range idle {
+		if uploadHost(pooled.UploadURL) != host {
+			kept = append(kept, pooled)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(state.uploads, upload.BucketID)
+		return
+	}
+
+	state.uploads[upload.BucketID] = kept
+}
+
+// uploadHost the host of an upload URL, or the whole URL if it cannot be parsed
+func uploadHost(uploadURL string) string {
+	parsed, err := url.Parse(uploadURL)
+	if err != nil {
+		return uploadURL
+	}
+
+	return parsed.Host
+}
+
+// podUnavailable tells whether err means the pod behind an upload URL is busy or down, see discardUploadHost
+func podUnavailable(err error) bool {
+	var b2Err *Err
+	return errors.As(err, &b2Err) && (b2Err.Status == http.StatusServiceUnavailable || b2Err.Status == http.StatusTooManyRequests)
+}
+
+// sha1AtEndReader passes the data of r through while hashing it, then appends the hex SHA1 of it
+type sha1AtEndReader struct {
+	r      io.Reader
+	hash   hash.Hash
+	digest []byte
+	eof    bool
+}
+
+// newSha1AtEndReader wraps r to be uploaded with Sha1AtEnd, the uploaded size grows by 40 bytes
+func newSha1AtEndReader(r io.Reader) *sha1AtEndReader {
+	return &sha1AtEndReader{r: r, hash: sha1.New()}
+}
+
+func (s *sha1AtEndReader) Read(p []byte) (int, error) {
+	if !s.eof {
+		n, err := s.r.Read(p)
+		s.hash.Write(p[:n])
+
+		if err != io.EOF {
+			return n, err
+		}
+
+		s.eof = true
+		s.digest = []byte(hex.EncodeToString(s.hash.Sum(nil)))
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	if len(s.digest) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, s.digest)
+	s.digest = s.digest[n:]
+
+	return n, nil
+}
+
+// UploadFileStreamingSha1 uploads one file to B2 from data that cannot be read twice, the SHA1 is computed while sending and appended to the content
+func (u *Upload) UploadFileStreamingSha1(data io.Reader, fileName string, fileSize int64, contentType string, mtime *time.Time, info map[string]string) (*FileInfo, error) {
+	return u.UploadFile(newSha1AtEndReader(data), fileName, fileSize+sha1HexLength, contentType, Sha1AtEnd, mtime, info)
+}
@@ -0,0 +1,49 @@
+package b2
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestUploadEmptyFile(t *testing.T) {
+	var sha1 string
+	var length int64
+	var transferEncoding []string
+	var body []byte
+	conn := newTestB2(t, func(w http.ResponseWriter, r *http.Request) {
+		sha1 = r.Header.Get("X-Bz-Content-Sha1")
+		length = r.ContentLength
+		transferEncoding = r.TransferEncoding
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"fileId":"id","fileName":"empty","contentLength":0,"contentSha1":"` + EmptySha1 + `"}`))
+	})
+
+	var sent *http.Request
+	conn.RequestHook = func(req *http.Request) {
+		sent = req
+	}
+
+	upload := &Upload{BucketID: "bucket", UploadURL: conn.APIUrl + "/upload", AuthToken: "upload", conn: conn}
+	info, err := upload.UploadFile(bytes.NewReader(nil), "empty", 0, "", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent.Body != http.NoBody {
+		t.Errorf("expected http.NoBody, got %T", sent.Body)
+	}
+
+	if sha1 != EmptySha1 {
+		t.Errorf("expected the SHA1 of no content, got %q", sha1)
+	}
+
+	if length != 0 || len(transferEncoding) != 0 || len(body) != 0 {
+		t.Errorf("expected an empty body of length 0, got length %d, transfer encoding %v and %d bytes", length, transferEncoding, len(body))
+	}
+
+	if info.ID != "id" || info.Length != 0 {
+		t.Errorf("unexpected file info %+v", info)
+	}
+}
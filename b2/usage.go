@@ -0,0 +1,155 @@
+package b2
+
+import (
+	"sync"
+)
+
+// BucketUsage the storage used by one bucket, counting every stored version of its files
+type BucketUsage struct {
+	BucketID string
+	Size     int64
+	Files    int64
+}
+
+// Usage the storage used by an account, per bucket name and in total
+type Usage struct {
+	Size    int64
+	Files   int64
+	Buckets map[string]BucketUsage
+}
+
+// AccountUsage sums the size and amount of the stored file versions of every bucket, listing up to Concurrency buckets at once.
+// B2 has no usage endpoint, so this lists every file version of the account: it is slow and costs one class C
+// transaction per listPageSize versions. Unfinished large files and hide markers are not counted
+func (b *B2) AccountUsage() (*Usage, error) {
+	conn, cancel := b.operation()
+	defer cancel()
+
+	buckets, err := conn.ListBuckets()
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := conn.bucketUsages(buckets, b.concurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{Buckets: make(map[string]BucketUsage, len(buckets))}
+	for i, bucket := range buckets {
+		usage.Size += usages[i].Size
+		usage.Files += usages[i].Files
+		usage.Buckets[bucket.Name] = usages[i]
+	}
+
+	return usage, nil
+}
+
+// bucketUsages sums the stored file versions of every bucket, listing up to concurrency buckets at once.
+// The first failure cancels the other listings
+func (b *B2) bucketUsages(buckets []Bucket, concurrency int) ([]BucketUsage, error) {
+	conn, cancel := b.operation()
+	defer cancel()
+
+	usages := make([]BucketUsage, len(buckets))
+	indexes := make(chan int)
+
+	// the first failure is kept, the ones after it come from cancelling the other listings
+	var firstErr error
+	var failed sync.Once
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				var err error
+				usages[index], err = conn.bucketUsage(buckets[index].ID)
+				if err != nil {
+					failed.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	for i := range buckets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return usages, nil
+}
+
+// BucketStats a bucket with the amount and size of its stored file versions
+type BucketStats struct {
+	Bucket Bucket
+	Files  int64
+	Size   int64
+}
+
+// ListBucketsWithStats lists every bucket with the amount and size of its stored file versions, listing up to concurrency buckets at once.
+// Like AccountUsage, it lists every file version of the account: it is slow and costs one class C transaction per listPageSize
+// versions. Cancel the context of the B2, see WithContext, to give up early
+func (b *B2) ListBucketsWithStats(concurrency int) ([]BucketStats, error) {
+	if concurrency < 1 {
+		return nil, ErrInvalidConcurrency
+	}
+
+	conn, cancel := b.operation()
+	defer cancel()
+
+	buckets, err := conn.ListBuckets()
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := conn.bucketUsages(buckets, concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]BucketStats, len(buckets))
+	for i, bucket := range buckets {
+		stats[i] = BucketStats{Bucket: bucket, Files: usages[i].Files, Size: usages[i].Size}
+	}
+
+	return stats, nil
+}
+
+// bucketUsage sums the size and amount of the stored file versions of one bucket
+func (b *B2) bucketUsage(bucketID string) (BucketUsage, error) {
+	usage := BucketUsage{BucketID: bucketID}
+	opts := &ListOptions{MaxFileCount: listPageSize}
+
+	for {
+		files, nextFileID, nextFileName, err := b.ListFileVersionsWithOptions(bucketID, opts)
+		if err != nil {
+			return usage, err
+		}
+
+		for _, file := range files {
+			if file.Action != ActionUpload {
+				continue
+			}
+
+			usage.Size += file.Size
+			usage.Files++
+		}
+
+		if nextFileName == "" {
+			return usage, nil
+		}
+
+		opts.StartFileName = nextFileName
+		opts.StartFileID = nextFileID
+	}
+}